@@ -1,202 +1,147 @@
 package ls
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"os"
-	"path/filepath"
-	"sort"
-	"time"
+	"runtime"
+	"slices"
+	"strings"
 
+	"github.com/antonmedv/jout/internal/cli"
 	"github.com/antonmedv/jout/internal/out"
+	"github.com/antonmedv/jout/pkg/ls"
 )
 
-type Entry struct {
-	Name       string `json:"name"`
-	Path       string `json:"path"`
-	Type       string `json:"type"`
-	IsDir      bool   `json:"is_dir"`
-	LinkTarget string `json:"link_target,omitempty"`
-	Size       int64  `json:"size_bytes"`
-	ModeStr    string `json:"mode_str"`
-	ModeOctal  string `json:"mode_octal"`
-	Inode      uint64 `json:"inode,omitempty"`
-	Nlink      uint64 `json:"nlink,omitempty"`
-	Uid        uint32 `json:"uid,omitempty"`
-	Gid        uint32 `json:"gid,omitempty"`
-	Owner      string `json:"owner,omitempty"`
-	Group      string `json:"group,omitempty"`
-	Mtime      string `json:"mtime"`
-	Atime      string `json:"atime,omitempty"`
-	Ctime      string `json:"ctime,omitempty"`
+// Entry is the record type of jout ls; see the ls library package.
+type Entry = ls.Entry
+
+func init() {
+	cli.Register(&cli.Command{
+		Name:     "ls",
+		Synopsis: "List directory contents.",
+		Usage:    "[-P|-H|-L] [-a|-A] [-d] [-R] [--max-depth N] [--one-file-system] [--tree] [--include GLOBS] [--exclude GLOBS] [--gitignore] [--detect] [--xattr] [--git] [--hash ALGS] [--archive|--archive-auto] [--format FORMAT] [path...]",
+		Flags:    func() *flag.FlagSet { return newFlagSet(&options{}) },
+		Run:      Run,
+	})
 }
 
-func makeEntry(name, fullPath string, info os.FileInfo) Entry {
-	// Determine type
-	t := "file"
-	if info.Mode()&os.ModeSymlink != 0 {
-		t = "symlink"
-	} else if info.IsDir() {
-		t = "dir"
-	}
-
-	var linkTarget string
-	if t == "symlink" {
-		if lt, err := os.Readlink(fullPath); err == nil {
-			linkTarget = lt
-		}
-	}
-
-	m := info.Mode()
-	x := getExtra(info)
-	var atimeStr, ctimeStr string
-	if !x.Atime.IsZero() {
-		atimeStr = x.Atime.Format(time.RFC3339)
-	}
-	if !x.Ctime.IsZero() {
-		ctimeStr = x.Ctime.Format(time.RFC3339)
-	}
-
-	return Entry{
-		Name:       name,
-		Path:       fullPath,
-		Type:       t,
-		IsDir:      info.IsDir(),
-		Size:       info.Size(),
-		ModeStr:    permString(m),
-		ModeOctal:  fmt.Sprintf("%04o", m.Perm()),
-		Mtime:      info.ModTime().UTC().Format(time.RFC3339),
-		Atime:      atimeStr,
-		Ctime:      ctimeStr,
-		Inode:      x.Inode,
-		Nlink:      x.Nlink,
-		Uid:        x.Uid,
-		Gid:        x.Gid,
-		Owner:      x.Owner,
-		Group:      x.Group,
-		LinkTarget: linkTarget,
-	}
+type options struct {
+	pFlag, lFlag, hFlag bool
+	hidden              string // "all", "almost" or "hide", by the last of -a, -A and --hide-dotfiles
+	directory           bool
+	recursive           bool
+	maxDepth            int
+	oneFileSystem       bool
+	tree                bool
+	include, exclude    string
+	gitignore           bool
+	detect              bool
+	xattr               bool
+	git                 bool
+	hash                string
+	archive             bool
+	archiveAuto         bool
+	hashMaxSize         int64
+	hashWorkers         int
+	out                 out.Options
 }
 
-// followMode controls how symlinks are handled
-// P: never follow; H: follow command-line argument only; L: follow everywhere
-type followMode int
-
-const (
-	followP followMode = iota
-	followH
-	followL
-)
-
-// abs returns the absolute form of p, or p itself if resolution fails.
-func abs(p string) string {
-	if ap, err := filepath.Abs(p); err == nil {
-		return ap
-	}
-	return p
+func newFlagSet(o *options) *flag.FlagSet {
+	fs := cli.NewFlagSet("ls")
+	fs.BoolVar(&o.pFlag, "P", false, "If argument is a symbolic link, list the link itself (do not follow). Cancels -H and -L.")
+	fs.BoolVar(&o.lFlag, "L", false, "Follow symlinks for all files.")
+	fs.BoolVar(&o.hFlag, "H", false, "Follow symlink on command-line argument only.")
+	fs.BoolFunc("a", "Include the . and .. entries of each directory.", func(string) error {
+		o.hidden = "all"
+		return nil
+	})
+	fs.BoolFunc("A", "Include entries starting with a dot, except . and .. (the default).", func(string) error {
+		o.hidden = "almost"
+		return nil
+	})
+	fs.BoolFunc("hide-dotfiles", "Omit entries starting with a dot, as ls does without -a or -A.", func(string) error {
+		o.hidden = "hide"
+		return nil
+	})
+	fs.BoolVar(&o.directory, "d", false, "List directories themselves, not their contents.")
+	fs.BoolVar(&o.recursive, "R", false, "List subdirectories recursively.")
+	fs.IntVar(&o.maxDepth, "max-depth", 0, "Descend at most this many levels below each path; implies -R (0 means no limit).")
+	fs.BoolVar(&o.oneFileSystem, "one-file-system", false, "Do not descend into directories on other file systems.")
+	fs.BoolVar(&o.tree, "tree", false, "Nest directory contents in a children array instead of a flat list; implies -R.")
+	fs.StringVar(&o.include, "include", "", "Comma-separated globs; keep only entries whose name matches one (patterns with a / match the relative path).")
+	fs.StringVar(&o.exclude, "exclude", "", "Comma-separated globs; omit matching entries and, when recursing, their contents.")
+	fs.BoolVar(&o.gitignore, "gitignore", false, "Omit entries ignored by .gitignore files, and .git directories.")
+	fs.BoolVar(&o.detect, "detect", false, "Sniff file contents to add mime_type, kind, arch and encoding.")
+	fs.BoolVar(&o.xattr, "xattr", false, "Add extended attributes, POSIX ACLs, file capabilities and SELinux context (Linux only).")
+	fs.BoolVar(&o.git, "git", false, "Add git status and the last commit that changed each entry, read from the repository directly.")
+	fs.StringVar(&o.hash, "hash", "", "Comma-separated hashes to compute for regular files: "+strings.Join(ls.HashAlgorithms, ", ")+".")
+	fs.Int64Var(&o.hashMaxSize, "hash-max-size", 0, "Do not hash files larger than this many bytes (0 means no limit).")
+	fs.IntVar(&o.hashWorkers, "hash-workers", runtime.NumCPU(), "Number of files to hash in parallel.")
+	fs.BoolVar(&o.archive, "archive", false, "Treat each path as a tar (optionally gzip or bzip2 compressed), zip or cpio archive and list its members.")
+	fs.BoolVar(&o.archiveAuto, "archive-auto", false, "List the members of paths detected as archives, and other paths as usual.")
+	o.out.AddFlags(fs)
+	return fs
 }
 
-// permString returns an ls-like permission string (e.g., "-rw-r--r--", "drwxr-xr-x").
-// It includes file type, rwx bits, and suid/sgid/sticky handling.
-func permString(m os.FileMode) string {
-	// Type character
-	typeCh := '-'
-	if m&os.ModeDir != 0 {
-		typeCh = 'd'
-	} else if m&os.ModeSymlink != 0 {
-		typeCh = 'l'
-	} else if m&os.ModeNamedPipe != 0 {
-		typeCh = 'p'
-	} else if m&os.ModeSocket != 0 {
-		typeCh = 's'
-	} else if m&os.ModeDevice != 0 {
-		if m&os.ModeCharDevice != 0 {
-			typeCh = 'c'
-		} else {
-			typeCh = 'b'
-		}
+func Run(args []string) (int, error) {
+	var o options
+	fs := newFlagSet(&o)
+	if err := fs.Parse(args); err != nil {
+		return cli.FlagError(fs, err)
 	}
 
-	// Permission bits
-	perm := m.Perm()
-	chars := [9]byte{'-', '-', '-', '-', '-', '-', '-', '-', '-'}
-	// User
-	if perm&0400 != 0 {
-		chars[0] = 'r'
-	}
-	if perm&0200 != 0 {
-		chars[1] = 'w'
+	mode := ls.FollowP // default behavior is -P
+	if o.pFlag {
+		mode = ls.FollowP
+	} else if o.lFlag {
+		mode = ls.FollowL
+	} else if o.hFlag {
+		mode = ls.FollowH
 	}
-	if perm&0100 != 0 {
-		chars[2] = 'x'
-	}
-	// Group
-	if perm&0040 != 0 {
-		chars[3] = 'r'
-	}
-	if perm&0020 != 0 {
-		chars[4] = 'w'
-	}
-	if perm&0010 != 0 {
-		chars[5] = 'x'
-	}
-	// Other
-	if perm&0004 != 0 {
-		chars[6] = 'r'
-	}
-	if perm&0002 != 0 {
-		chars[7] = 'w'
-	}
-	if perm&0001 != 0 {
-		chars[8] = 'x'
+
+	if o.maxDepth < 0 {
+		return 2, errors.New("--max-depth must not be negative")
 	}
 
-	// suid/sgid/sticky modifications
-	if m&os.ModeSetuid != 0 {
-		if chars[2] == 'x' {
-			chars[2] = 's'
-		} else {
-			chars[2] = 'S'
+	if o.archive || o.archiveAuto {
+		// Archive members are listed without being read.
+		archiveFlag := "--archive"
+		if o.archiveAuto {
+			archiveFlag = "--archive-auto"
 		}
-	}
-	if m&os.ModeSetgid != 0 {
-		if chars[5] == 'x' {
-			chars[5] = 's'
-		} else {
-			chars[5] = 'S'
+		for _, f := range []struct {
+			name string
+			set  bool
+		}{{"--hash", o.hash != ""}, {"--detect", o.detect}, {"--git", o.git}, {"--xattr", o.xattr}, {"--gitignore", o.gitignore}} {
+			if f.set {
+				return 2, fmt.Errorf("%s cannot be combined with %s", f.name, archiveFlag)
+			}
 		}
 	}
-	if m&os.ModeSticky != 0 {
-		if chars[8] == 'x' {
-			chars[8] = 't'
-		} else {
-			chars[8] = 'T'
+
+	var hashes []string
+	if o.hash != "" {
+		hashes = strings.Split(o.hash, ",")
+		for _, h := range hashes {
+			if !slices.Contains(ls.HashAlgorithms, h) {
+				return 2, fmt.Errorf("unknown hash %q; expected one of %s", h, strings.Join(ls.HashAlgorithms, ", "))
+			}
 		}
 	}
 
-	return string(append([]byte{byte(typeCh)}, chars[:]...))
-}
-
-func Run(args []string) (int, error) {
-	fs := flag.NewFlagSet("ls", flag.ContinueOnError)
-	fs.SetOutput(os.Stderr)
-
-	var pFlag, lFlag, hFlag bool
-	fs.BoolVar(&pFlag, "P", false, "If argument is a symbolic link, list the link itself (do not follow). Cancels -H and -L.")
-	fs.BoolVar(&lFlag, "L", false, "Follow symlinks for all files.")
-	fs.BoolVar(&hFlag, "H", false, "Follow symlink on command-line argument only.")
-
-	if err := fs.Parse(args); err != nil {
-		return 2, nil
+	var include, exclude []string
+	if o.include != "" {
+		include = strings.Split(o.include, ",")
 	}
-
-	mode := followP // default behavior is -P
-	if pFlag {
-		mode = followP
-	} else if lFlag {
-		mode = followL
-	} else if hFlag {
-		mode = followH
+	if o.exclude != "" {
+		exclude = strings.Split(o.exclude, ",")
+	}
+	for _, p := range slices.Concat(include, exclude) {
+		if !ls.ValidPattern(p) {
+			return 2, fmt.Errorf("invalid glob %q", p)
+		}
 	}
 
 	targets := fs.Args()
@@ -204,87 +149,64 @@ func Run(args []string) (int, error) {
 		targets = []string{"."}
 	}
 
-	aggregated := make([]Entry, 0)
+	o.out.Args = args
+	w, err := out.NewWriter[Entry](o.out)
+	if err != nil {
+		return 2, err
+	}
+	var writeErr error
+	emit := func(e Entry) error {
+		writeErr = w.Write(e)
+		return writeErr
+	}
+
+	opts := ls.Options{
+		Follow:        mode,
+		Unsorted:      w.Streaming(),
+		All:           o.hidden == "all",
+		HideDotfiles:  o.hidden == "hide",
+		Directory:     o.directory,
+		Include:       include,
+		Exclude:       exclude,
+		GitIgnore:     o.gitignore,
+		Recursive:     o.recursive || o.tree || o.maxDepth > 0,
+		MaxDepth:      o.maxDepth,
+		OneFileSystem: o.oneFileSystem,
+		Tree:          o.tree,
+		Detect:        o.detect,
+		Xattr:         o.xattr,
+		Git:           o.git,
+		Hash:          hashes,
+		HashMaxSize:   o.hashMaxSize,
+		HashWorkers:   o.hashWorkers,
+		OnError: func(err error) {
+			e := out.NewItemError("lstat", err)
+			w.Error(e)
+			cli.Report("ls", cli.CodeItem, e)
+		},
+	}
+
 	exitCode := 0
 	for _, t := range targets {
-		items, err := listPath(t, mode)
-		if err != nil {
+		list := ls.List
+		if o.archive || o.archiveAuto && ls.IsArchive(t) {
+			list = ls.ListArchive
+		}
+		if err := list(context.Background(), t, opts, emit); err != nil {
+			if writeErr != nil {
+				return 1, writeErr
+			}
 			// Report via exit code but keep collecting from other targets
 			exitCode = 1
+			e := out.NewItemError("open", err)
+			w.Error(e)
+			cli.Report("ls", cli.CodeItem, e)
 			continue
 		}
-		aggregated = append(aggregated, items...)
-	}
-
-	out.JSON(aggregated)
-	return exitCode, nil
-}
-
-func listPath(path string, mode followMode) ([]Entry, error) {
-	// Determine info for target based on follow mode
-	var info os.FileInfo
-	var err error
-
-	switch mode {
-	case followL:
-		info, err = os.Stat(path)
-		if err != nil {
-			// Fallback to Lstat so broken symlinks can still be listed
-			info, err = os.Lstat(path)
-		}
-	case followH:
-		info, err = os.Lstat(path)
-		if err == nil && (info.Mode()&os.ModeSymlink) != 0 {
-			if si, serr := os.Stat(path); serr == nil {
-				info = si
-			}
-		}
-	default: // followP
-		info, err = os.Lstat(path)
-	}
-	if err != nil {
-		return nil, err
 	}
 
-	// Non-directory target: return single Entry
-	if !info.IsDir() {
-		return []Entry{makeEntry(filepath.Base(path), abs(path), info)}, nil
-	}
-
-	// Directory case: list children of (possibly dereferenced) path.
-	// Note: opening by original path is fine since symlink to dir is handled at info stage for H/L
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	if err := w.Close(); err != nil {
+		return 1, err
 	}
-	defer f.Close()
-
-	de, err := f.ReadDir(-1)
-	if err != nil {
-		return nil, err
-	}
-
-	items := make([]Entry, 0, len(de))
-	for _, d := range de {
-		joined := filepath.Join(path, d.Name())
-		var fi os.FileInfo
-		if mode == followL {
-			fi, err = os.Stat(joined)
-			if err != nil {
-				// Fallback to Lstat to at least report symlink itself
-				fi, err = os.Lstat(joined)
-			}
-		} else {
-			fi, err = os.Lstat(joined)
-		}
-		if err != nil {
-			// Skip entries we cannot stat, collect partial results like ls
-			continue
-		}
-		items = append(items, makeEntry(d.Name(), abs(joined), fi))
-	}
-
-	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
-
-	return items, nil
+	return exitCode, nil
 }
@@ -0,0 +1,68 @@
+package lsof
+
+import (
+	"context"
+	"flag"
+
+	"github.com/antonmedv/jout/internal/cli"
+	"github.com/antonmedv/jout/internal/out"
+	"github.com/antonmedv/jout/pkg/ps"
+)
+
+// OpenFile is the record type of jout lsof; see the ps library package.
+type OpenFile = ps.OpenFile
+
+func init() {
+	cli.Register(&cli.Command{
+		Name:      "lsof",
+		Synopsis:  "List the files open in the current processes.",
+		Usage:     "[--user USER] [--format FORMAT]",
+		Platforms: []string{"linux"},
+		Flags:     func() *flag.FlagSet { return newFlagSet(&options{}) },
+		Run:       Run,
+	})
+}
+
+type options struct {
+	userFilter string
+	out        out.Options
+}
+
+func newFlagSet(o *options) *flag.FlagSet {
+	fs := cli.NewFlagSet("lsof")
+	fs.StringVar(&o.userFilter, "user", "", "Filter by the user name of the owning process")
+	o.out.AddFlags(fs)
+	return fs
+}
+
+func Run(args []string) (int, error) {
+	var o options
+	fs := newFlagSet(&o)
+	if err := fs.Parse(args); err != nil {
+		return cli.FlagError(fs, err)
+	}
+
+	o.out.Args = args
+	w, err := out.NewWriter[*OpenFile](o.out)
+	if err != nil {
+		return 2, err
+	}
+	opts := ps.Options{
+		User: o.userFilter,
+		OnError: func(pid int, err error) {
+			e := out.NewItemError("read", err)
+			e.PID = pid
+			w.Error(e)
+			cli.Report("lsof", cli.CodeItem, e)
+		},
+	}
+	err = ps.OpenFiles(context.Background(), opts, w.Write)
+	if err != nil {
+		return 1, err
+	}
+
+	if err := w.Close(); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
@@ -1,101 +1,107 @@
 package ps
 
 import (
+	"context"
+	"errors"
 	"flag"
-	"os"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/antonmedv/jout/internal/cli"
 	"github.com/antonmedv/jout/internal/out"
+	"github.com/antonmedv/jout/pkg/ps"
 )
 
-type Process struct {
-	// Identity
-	PID   int    `json:"pid"`
-	PPID  int    `json:"ppid"`
-	UID   uint32 `json:"uid"`
-	GID   uint32 `json:"gid"`
-	User  string `json:"user"`
-	Group string `json:"group"`
-
-	// State / terminal
-	State   string `json:"state"`   // R|S|D|T|Z|I (running,sleeping,io wait,stopped,zombie,idle)
-	TTY     string `json:"tty"`     // "pts/0", "tty1"; null if none (kept without omitempty to emit null)
-	Comm    string `json:"comm"`    // short name, e.g. "sshd"
-	Command string `json:"command"` // argv vector; may be empty if restricted
-
-	// Paths
-	Exe string `json:"exe,omitempty"` // resolved binary path
-	Cwd string `json:"cwd,omitempty"` // working directory
-
-	// CPU & memory (cumulative since start)
-	CPUUserSeconds   float64 `json:"cpu_user_seconds"`
-	CPUSystemSeconds float64 `json:"cpu_system_seconds"`
-	MemRSSBytes      int64   `json:"mem_rss_bytes"`
-	MemVMSBytes      int64   `json:"mem_vms_bytes"`
-	MemSwapBytes     int64   `json:"mem_swap_bytes,omitempty"` // if available
-
-	Threads  *int `json:"threads,omitempty"`
-	Nice     *int `json:"nice,omitempty"`
-	Priority *int `json:"priority,omitempty"`
-
-	// Start/elapsed
-	StartTime       string `json:"start_time"`         // RFC3339 UTC
-	StartTimeUnixNs int64  `json:"start_time_unix_ns"` // monotonic-friendly
-	ElapsedSeconds  *int64 `json:"elapsed_seconds,omitempty"`
-
-	// Containers / cgroups / namespaces (Linux)
-	Cgroup      *string         `json:"cgroup,omitempty"`  // primary/legacy cgroup path
-	Cgroups     *[]string       `json:"cgroups,omitempty"` // all cgroup paths (v1/v2)
-	NS          *ProcNamespaces `json:"namespaces,omitempty"`
-	ContainerID *string         `json:"container_id,omitempty"` // docker/cri
-
-	// I/O stats (Linux)
-	IO *ProcIO `json:"io,omitempty"`
-
-	// Security labels (Linux, optional)
-	SELinuxLabel *string `json:"selinux_label,omitempty"`
+// Process is the record type of jout ps; see the ps library package.
+type Process = ps.Process
+
+func init() {
+	cli.Register(&cli.Command{
+		Name:      "ps",
+		Synopsis:  "Report a snapshot of the current processes.",
+		Usage:     "[--user USER] [--sample DURATION] [--mem-detail] [--env [--env-redact GLOBS]] [--fds] [--format FORMAT]",
+		Platforms: []string{"linux", "darwin", "windows"},
+		Flags:     func() *flag.FlagSet { return newFlagSet(&options{}) },
+		Run:       Run,
+	})
 }
 
-type ProcIO struct {
-	ReadBytes  uint64 `json:"read_bytes"`
-	WriteBytes uint64 `json:"write_bytes"`
+type options struct {
+	userFilter string
+	sample     time.Duration
+	memDetail  bool
+	env        bool
+	envRedact  string
+	fds        bool
+	out        out.Options
 }
 
-type ProcNamespaces struct {
-	Mnt    string `json:"mnt,omitempty"`
-	PID    string `json:"pid,omitempty"`
-	Net    string `json:"net,omitempty"`
-	UTS    string `json:"uts,omitempty"`
-	IPC    string `json:"ipc,omitempty"`
-	User   string `json:"user,omitempty"`
-	Cgroup string `json:"cgroup,omitempty"`
+func newFlagSet(o *options) *flag.FlagSet {
+	fs := cli.NewFlagSet("ps")
+	fs.StringVar(&o.userFilter, "user", "", "Filter processes by user name")
+	fs.DurationVar(&o.sample, "sample", 0, "Read processes twice this far apart (e.g. 1s) and add cpu_percent and per-second I/O and major fault rates")
+	fs.BoolVar(&o.memDetail, "mem-detail", false, "Add mem_detail with PSS, USS, shared, anonymous, file-backed and swap PSS bytes from smaps_rollup (Linux)")
+	fs.BoolVar(&o.env, "env", false, "Add env with the environment of each process (Linux); values of secret-looking variables are redacted")
+	fs.StringVar(&o.envRedact, "env-redact", strings.Join(ps.DefaultEnvRedact, ","), "Comma-separated globs, matched ignoring case; redact the values of variables whose name matches one (empty redacts nothing)")
+	fs.BoolVar(&o.fds, "fds", false, "Add fds with the open file descriptors of each process, as listed by jout lsof (Linux)")
+	o.out.AddFlags(fs)
+	return fs
 }
 
 func Run(args []string) (int, error) {
-	fs := flag.NewFlagSet("ps", flag.ContinueOnError)
-	fs.SetOutput(os.Stderr)
-
-	var userFilter string
-	fs.StringVar(&userFilter, "user", "", "Filter processes by user name")
-
+	var o options
+	fs := newFlagSet(&o)
 	if err := fs.Parse(args); err != nil {
-		return 2, nil
+		return cli.FlagError(fs, err)
+	}
+	if o.sample < 0 {
+		return 2, errors.New("--sample must not be negative")
+	}
+	redact := []string{}
+	if o.envRedact != "" {
+		redact = strings.Split(o.envRedact, ",")
+	}
+	for _, p := range redact {
+		if !ps.ValidEnvPattern(p) {
+			return 2, fmt.Errorf("invalid glob %q", p)
+		}
 	}
 
-	procs, err := collectProcesses()
+	o.out.Args = args
+	w, err := out.NewWriter[*Process](o.out)
+	if err != nil {
+		return 2, err
+	}
+	opts := ps.Options{
+		User:      o.userFilter,
+		Sample:    o.sample,
+		MemDetail: o.memDetail,
+		Env:       o.env,
+		EnvRedact: redact,
+		FDs:       o.fds,
+		OnError: func(pid int, err error) {
+			e := out.NewItemError("read", err)
+			e.PID = pid
+			w.Error(e)
+			cli.Report("ps", cli.CodeItem, e)
+		},
+		// Details such as the exe of other users' processes are left out
+		// for most processes without privileges; only the envelope lists
+		// them.
+		OnDetailError: func(pid int, err error) {
+			e := out.NewItemError("read", err)
+			e.PID = pid
+			w.Error(e)
+		},
+	}
+	err = ps.Each(context.Background(), opts, w.Write)
 	if err != nil {
 		return 1, err
 	}
 
-	if userFilter != "" {
-		filtered := make([]*Process, 0, len(procs))
-		for _, p := range procs {
-			if p != nil && p.User == userFilter {
-				filtered = append(filtered, p)
-			}
-		}
-		procs = filtered
+	if err := w.Close(); err != nil {
+		return 1, err
 	}
-
-	out.JSON(procs)
 	return 0, nil
 }
@@ -0,0 +1,46 @@
+package schema
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/antonmedv/jout/internal/cli"
+	"github.com/antonmedv/jout/internal/out"
+	"github.com/antonmedv/jout/internal/schema"
+)
+
+func init() {
+	cli.Register(&cli.Command{
+		Name:     "schema",
+		Synopsis: "Print the JSON Schema of a command's output.",
+		Usage:    "[command]",
+		Flags:    func() *flag.FlagSet { return cli.NewFlagSet("schema") },
+		Run:      Run,
+	})
+}
+
+// Run prints the schema of the named command, or the list of commands that
+// have one when called without arguments.
+func Run(args []string) (int, error) {
+	fs := cli.NewFlagSet("schema")
+	if err := fs.Parse(args); err != nil {
+		return cli.FlagError(fs, err)
+	}
+
+	if fs.NArg() == 0 {
+		out.JSON(schema.Commands())
+		return 0, nil
+	}
+	if fs.NArg() > 1 {
+		return 2, fmt.Errorf("schema: expected one command, got %d", fs.NArg())
+	}
+
+	b, ok := schema.Get(fs.Arg(0))
+	if !ok {
+		return 2, fmt.Errorf("schema: no schema for %q (available: %s)", fs.Arg(0), strings.Join(schema.Commands(), ", "))
+	}
+	os.Stdout.Write(b)
+	return 0, nil
+}
@@ -1,3 +1,3 @@
-module github.com/webpod/jout
+module github.com/antonmedv/jout
 
 go 1.23.6
@@ -0,0 +1,158 @@
+// Package blake2b implements the unkeyed BLAKE2b hash function as defined in
+// RFC 7693, so that jout can offer it without external dependencies.
+package blake2b
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	// BlockSize is the block size of BLAKE2b in bytes.
+	BlockSize = 128
+	// Size is the size of a BLAKE2b-512 checksum in bytes.
+	Size = 64
+)
+
+var iv = [8]uint64{
+	0x6a09e667f3bcc908, 0xbb67ae8584caa73b, 0x3c6ef372fe94f82b, 0xa54ff53a5f1d36f1,
+	0x510e527fade682d1, 0x9b05688c2b3e6c1f, 0x1f83d9abfb41bd6b, 0x5be0cd19137e2179,
+}
+
+var sigma = [12][16]byte{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	{14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3},
+	{11, 8, 12, 0, 5, 2, 15, 13, 10, 14, 3, 6, 7, 1, 9, 4},
+	{7, 9, 3, 1, 13, 12, 11, 14, 2, 6, 5, 10, 4, 0, 15, 8},
+	{9, 0, 5, 7, 2, 4, 10, 15, 14, 1, 11, 12, 6, 8, 3, 13},
+	{2, 12, 6, 10, 0, 11, 8, 3, 4, 13, 7, 5, 15, 14, 1, 9},
+	{12, 5, 1, 15, 14, 13, 4, 10, 0, 7, 6, 3, 9, 2, 8, 11},
+	{13, 11, 7, 14, 12, 1, 3, 9, 5, 0, 15, 4, 8, 6, 2, 10},
+	{6, 15, 14, 9, 11, 3, 0, 8, 12, 2, 13, 7, 1, 4, 10, 5},
+	{10, 2, 8, 4, 7, 6, 1, 5, 15, 11, 9, 14, 3, 12, 13, 0},
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	{14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3},
+}
+
+type digest struct {
+	h    [8]uint64
+	t    [2]uint64 // byte counter, low and high words
+	buf  [BlockSize]byte
+	n    int // bytes in buf
+	size int
+}
+
+// New512 returns a hash.Hash computing the BLAKE2b-512 checksum.
+func New512() hash.Hash { return New(Size) }
+
+// New returns a hash.Hash computing a BLAKE2b checksum of size bytes,
+// which must be between 1 and 64.
+func New(size int) hash.Hash {
+	if size < 1 || size > Size {
+		panic("blake2b: invalid digest size")
+	}
+	d := &digest{size: size}
+	d.Reset()
+	return d
+}
+
+// Sum512 returns the BLAKE2b-512 checksum of data.
+func Sum512(data []byte) [Size]byte {
+	var sum [Size]byte
+	d := New512()
+	d.Write(data)
+	d.Sum(sum[:0])
+	return sum
+}
+
+func (d *digest) Size() int      { return d.size }
+func (d *digest) BlockSize() int { return BlockSize }
+
+func (d *digest) Reset() {
+	d.h = iv
+	// Parameter block: digest length, no key, fanout 1, depth 1.
+	d.h[0] ^= 0x01010000 ^ uint64(d.size)
+	d.t = [2]uint64{}
+	d.n = 0
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	written := len(p)
+	// The last block is compressed with the final flag set, so a full
+	// buffer is only compressed once more input is known to follow.
+	if d.n > 0 {
+		c := copy(d.buf[d.n:], p)
+		d.n += c
+		p = p[c:]
+		if len(p) == 0 {
+			return written, nil
+		}
+		d.compress(d.buf[:], BlockSize, false)
+		d.n = 0
+	}
+	for len(p) > BlockSize {
+		d.compress(p[:BlockSize], BlockSize, false)
+		p = p[BlockSize:]
+	}
+	d.n = copy(d.buf[:], p)
+	return written, nil
+}
+
+func (d *digest) Sum(in []byte) []byte {
+	c := *d // leave d usable for further writes
+	clear(c.buf[c.n:])
+	c.compress(c.buf[:], c.n, true)
+	var out [Size]byte
+	for i, v := range c.h {
+		binary.LittleEndian.PutUint64(out[i*8:], v)
+	}
+	return append(in, out[:c.size]...)
+}
+
+// compress mixes one block into the state, counting n new bytes of input.
+func (d *digest) compress(block []byte, n int, last bool) {
+	d.t[0] += uint64(n)
+	if d.t[0] < uint64(n) {
+		d.t[1]++
+	}
+
+	var m [16]uint64
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint64(block[i*8:])
+	}
+	var v [16]uint64
+	copy(v[:8], d.h[:])
+	copy(v[8:], iv[:])
+	v[12] ^= d.t[0]
+	v[13] ^= d.t[1]
+	if last {
+		v[14] = ^v[14]
+	}
+
+	for _, s := range sigma {
+		g(&v, 0, 4, 8, 12, m[s[0]], m[s[1]])
+		g(&v, 1, 5, 9, 13, m[s[2]], m[s[3]])
+		g(&v, 2, 6, 10, 14, m[s[4]], m[s[5]])
+		g(&v, 3, 7, 11, 15, m[s[6]], m[s[7]])
+		g(&v, 0, 5, 10, 15, m[s[8]], m[s[9]])
+		g(&v, 1, 6, 11, 12, m[s[10]], m[s[11]])
+		g(&v, 2, 7, 8, 13, m[s[12]], m[s[13]])
+		g(&v, 3, 4, 9, 14, m[s[14]], m[s[15]])
+	}
+	for i := range d.h {
+		d.h[i] ^= v[i] ^ v[i+8]
+	}
+}
+
+// g is the BLAKE2b mixing function.
+func g(v *[16]uint64, a, b, c, d int, x, y uint64) {
+	v[a] = v[a] + v[b] + x
+	v[d] = bits.RotateLeft64(v[d]^v[a], -32)
+	v[c] = v[c] + v[d]
+	v[b] = bits.RotateLeft64(v[b]^v[c], -24)
+	v[a] = v[a] + v[b] + y
+	v[d] = bits.RotateLeft64(v[d]^v[a], -16)
+	v[c] = v[c] + v[d]
+	v[b] = bits.RotateLeft64(v[b]^v[c], -63)
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Command describes a jout subcommand. Commands register themselves from
+// their package init and everything user-facing (dispatch, help, usage and
+// suggestions) is generated from the registry.
+type Command struct {
+	Name      string   // e.g. "ls"
+	Synopsis  string   // one-line description shown by `jout help`
+	Usage     string   // arguments synopsis, e.g. "[-P|-H|-L] [path...]"
+	Platforms []string // supported GOOS values; empty means all
+
+	// Flags returns a fresh flag set with the command's flags defined.
+	// It is only used to render help; Run builds and parses its own.
+	Flags func() *flag.FlagSet
+
+	Run func(args []string) (int, error)
+}
+
+var commands = map[string]*Command{}
+
+// Register adds c to the registry. It panics on duplicate names.
+func Register(c *Command) {
+	if _, dup := commands[c.Name]; dup {
+		panic("cli: duplicate command " + c.Name)
+	}
+	commands[c.Name] = c
+}
+
+// Lookup returns the registered command with the given name.
+func Lookup(name string) (*Command, bool) {
+	c, ok := commands[name]
+	return c, ok
+}
+
+// Commands returns all registered commands sorted by name.
+func Commands() []*Command {
+	list := make([]*Command, 0, len(commands))
+	for _, c := range commands {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Supported reports whether c runs on the current platform.
+func (c *Command) Supported() bool {
+	if len(c.Platforms) == 0 {
+		return true
+	}
+	for _, p := range c.Platforms {
+		if p == runtime.GOOS {
+			return true
+		}
+	}
+	return false
+}
+
+// NewFlagSet returns a flag set for the named command that reports parse
+// errors on stderr and prints the generated command help for -h/--help.
+// With JSONErrors the flag package stays quiet and FlagError takes over.
+func NewFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	if JSONErrors {
+		fs.SetOutput(io.Discard)
+	}
+	fs.Usage = func() { printFlagSetHelp(fs) }
+	return fs
+}
+
+func printFlagSetHelp(fs *flag.FlagSet) {
+	if c, ok := Lookup(fs.Name()); ok {
+		c.printHelp(fs.Output(), fs)
+	} else {
+		fs.PrintDefaults()
+	}
+}
+
+// Help prints the help text of c to w.
+func (c *Command) Help(w io.Writer) {
+	var fs *flag.FlagSet
+	if c.Flags != nil {
+		fs = c.Flags()
+	}
+	c.printHelp(w, fs)
+}
+
+func (c *Command) printHelp(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "jout %s — %s\n", c.Name, c.Synopsis)
+	fmt.Fprintln(w, "usage:")
+	fmt.Fprintf(w, "  %s\n", c.usageLine())
+	if len(c.Platforms) > 0 {
+		fmt.Fprintf(w, "platforms: %s\n", strings.Join(c.Platforms, ", "))
+	}
+	if fs != nil && hasFlags(fs) {
+		fmt.Fprintln(w, "flags:")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+}
+
+func (c *Command) usageLine() string {
+	if c.Usage == "" {
+		return "jout " + c.Name
+	}
+	return "jout " + c.Name + " " + c.Usage
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	n := 0
+	fs.VisitAll(func(*flag.Flag) { n++ })
+	return n > 0
+}
+
+// Usage prints the top-level usage listing every registered command.
+func Usage(w io.Writer) {
+	fmt.Fprintln(w, "jout — Run commands, get JSON.")
+	fmt.Fprintln(w, "usage:")
+	for _, c := range Commands() {
+		fmt.Fprintf(w, "  %s\n", c.usageLine())
+	}
+	fmt.Fprintln(w, "  jout help [command]")
+	fmt.Fprintln(w, "commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range Commands() {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.Name, c.Synopsis)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "global flags:")
+	fmt.Fprintln(w, "  --json-errors     Report errors as JSON objects on stderr; the default when stderr is not a terminal.")
+	fmt.Fprintln(w, "  --no-json-errors  Report errors as text even when stderr is not a terminal.")
+	fmt.Fprintln(w, "  JOUT_JSON_ERRORS=1|0 in the environment sets the default; the flags may appear anywhere before --.")
+}
+
+// Suggest returns the registered command name closest to name, or "" if
+// nothing is close enough to be a plausible typo.
+func Suggest(name string) string {
+	best, bestDist := "", 3
+	for _, c := range Commands() {
+		if d := distance(name, c.Name); d < bestDist && d < len(name) {
+			best, bestDist = c.Name, d
+		}
+	}
+	return best
+}
+
+// distance is the edit distance between a and b, counting insertions,
+// deletions, substitutions and transpositions of adjacent bytes.
+func distance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
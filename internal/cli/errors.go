@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/antonmedv/jout/internal/out"
+)
+
+// JSONErrors makes errors print as JSON objects, one per line, on stderr.
+var JSONErrors bool
+
+// Error codes used in JSON error reports.
+const (
+	CodeUsage               = "usage"                // bad flags or arguments; exit status 2
+	CodeUnknownCommand      = "unknown_command"      // no such subcommand
+	CodeUnsupportedPlatform = "unsupported_platform" // command does not run on this OS
+	CodeRuntime             = "runtime"              // the command failed
+	CodeItem                = "item"                 // one path or process failed; the command carried on
+)
+
+// UsageError is a flag parse error. In text mode the flag package has
+// already printed it along with the command help.
+type UsageError struct {
+	Err error
+}
+
+func (e *UsageError) Error() string { return e.Err.Error() }
+func (e *UsageError) Unwrap() error { return e.Err }
+
+// FlagError turns the error from parsing fs into the command's result:
+// success for -h/--help, a usage error otherwise.
+func FlagError(fs *flag.FlagSet, err error) (int, error) {
+	if err == flag.ErrHelp {
+		if JSONErrors {
+			fs.SetOutput(os.Stderr)
+			printFlagSetHelp(fs)
+		}
+		return 0, nil
+	}
+	return 2, &UsageError{Err: err}
+}
+
+// Report writes err to stderr. In JSON mode it is an object with error,
+// code, command and, when known, errno, op, path and pid. Errors that
+// marshal themselves (such as --where syntax errors) contribute their own
+// fields in both modes.
+func Report(command, code string, err error) {
+	var jsonErr json.Marshaler
+	hasJSON := errors.As(err, &jsonErr)
+	if !JSONErrors {
+		var usageErr *UsageError
+		var itemErr *out.ItemError
+		switch {
+		case errors.As(err, &usageErr):
+			// Already printed by the flag package.
+		case hasJSON:
+			writeJSON(jsonErr)
+		case errors.As(err, &itemErr):
+			fmt.Fprintf(os.Stderr, "jout %s: %s\n", command, err)
+		default:
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+		return
+	}
+
+	r := map[string]any{
+		"error": err.Error(),
+		"code":  code,
+	}
+	if command != "" {
+		r["command"] = command
+	}
+	if errno := out.Errno(err); errno != "" {
+		r["errno"] = errno
+	}
+	var itemErr *out.ItemError
+	if errors.As(err, &itemErr) {
+		r["op"] = itemErr.Op
+		if itemErr.Path != "" {
+			r["path"] = itemErr.Path
+		}
+		if itemErr.PID != 0 {
+			r["pid"] = itemErr.PID
+		}
+	}
+	if hasJSON {
+		var extra map[string]any
+		if b, err := json.Marshal(jsonErr); err == nil && json.Unmarshal(b, &extra) == nil {
+			for k, v := range extra {
+				if _, ok := r[k]; !ok {
+					r[k] = v
+				}
+			}
+		}
+	}
+	writeJSON(r)
+}
+
+func writeJSON(v any) {
+	enc := json.NewEncoder(os.Stderr)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
@@ -0,0 +1,256 @@
+// Package expr implements the small filter language behind --where:
+//
+//	mem_rss_bytes > 500e6 && comm =~ "^java"
+//	type == "file" && !(size_bytes < 1e9)
+//
+// Operands are field names (dotted JSON paths), numbers, strings, true,
+// false and null. Operators, from lowest to highest precedence, are ||, &&,
+// !, and the comparisons == != < <= > >= =~ !~. Expressions are type
+// checked against the record's fields when parsed.
+package expr
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Type is the static type of a field or expression.
+type Type int
+
+const (
+	Any Type = iota // arrays, objects: only comparable with null
+	Number
+	String
+	Bool
+	Null
+)
+
+func (t Type) String() string {
+	switch t {
+	case Number:
+		return "number"
+	case String:
+		return "string"
+	case Bool:
+		return "bool"
+	case Null:
+		return "null"
+	}
+	return "value"
+}
+
+// SyntaxError describes an invalid expression. It encodes as JSON so that
+// callers can report it in machine-readable form.
+type SyntaxError struct {
+	Expr string
+	Pos  int // byte offset into Expr
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("where: %s at position %d", e.Msg, e.Pos)
+}
+
+func (e *SyntaxError) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false) // keep operators such as > and && readable
+	err := enc.Encode(struct {
+		Error    string `json:"error"`
+		Expr     string `json:"expression"`
+		Position int    `json:"position"`
+	}{e.Msg, e.Expr, e.Pos})
+	return bytes.TrimSpace(b.Bytes()), err
+}
+
+// Expr is a parsed, type-checked expression.
+type Expr struct {
+	root node
+}
+
+// Parse compiles src. fields maps every valid field name to its type.
+func Parse(src string, fields map[string]Type) (*Expr, error) {
+	p := &parser{lex: lexer{src: src}, fields: fields}
+	p.next()
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf(p.tok.pos, "unexpected %s", p.tok)
+	}
+	if n.typ() != Bool {
+		return nil, p.errorf(0, "expression is %s, not bool", n.typ())
+	}
+	return &Expr{root: n}, nil
+}
+
+// Match evaluates the expression for one record. get returns the value of
+// a field as decoded from JSON (json.Number, string, bool or nil), and nil
+// for fields the record does not have.
+func (e *Expr) Match(get func(field string) any) bool {
+	v, _ := e.root.eval(get).(bool)
+	return v
+}
+
+// --- AST ---
+
+type node interface {
+	typ() Type
+	eval(get func(string) any) any
+}
+
+type literal struct {
+	t Type
+	v any
+}
+
+func (n *literal) typ() Type                 { return n.t }
+func (n *literal) eval(func(string) any) any { return n.v }
+
+type field struct {
+	name string
+	t    Type
+}
+
+func (n *field) typ() Type { return n.t }
+func (n *field) eval(get func(string) any) any {
+	switch v := get(n.name).(type) {
+	case json.Number:
+		return parseNumber(string(v))
+	default:
+		return v
+	}
+}
+
+type not struct{ x node }
+
+func (n *not) typ() Type { return Bool }
+func (n *not) eval(get func(string) any) any {
+	v, _ := n.x.eval(get).(bool)
+	return !v
+}
+
+type logical struct {
+	op   string // "&&" or "||"
+	l, r node
+}
+
+func (n *logical) typ() Type { return Bool }
+func (n *logical) eval(get func(string) any) any {
+	l, _ := n.l.eval(get).(bool)
+	if n.op == "&&" && !l || n.op == "||" && l {
+		return l
+	}
+	r, _ := n.r.eval(get).(bool)
+	return r
+}
+
+type compare struct {
+	op   string
+	l, r node
+}
+
+func (n *compare) typ() Type { return Bool }
+func (n *compare) eval(get func(string) any) any {
+	l, r := n.l.eval(get), n.r.eval(get)
+	switch n.op {
+	case "==":
+		return equal(l, r)
+	case "!=":
+		return !equal(l, r)
+	}
+	c, ok := order(l, r)
+	if !ok {
+		return false // null or mismatched values are unordered
+	}
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+type match struct {
+	negate bool
+	x      node
+	re     *regexp.Regexp
+}
+
+func (n *match) typ() Type { return Bool }
+func (n *match) eval(get func(string) any) any {
+	s, ok := n.x.eval(get).(string)
+	if !ok {
+		return false
+	}
+	return n.re.MatchString(s) != n.negate
+}
+
+// number keeps integers exact so that large values such as nanosecond
+// timestamps compare correctly.
+type number struct {
+	f     float64
+	i     int64
+	exact bool
+}
+
+func parseNumber(s string) any {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return number{f: float64(i), i: i, exact: true}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return number{f: f}
+	}
+	return nil
+}
+
+func equal(l, r any) bool {
+	if c, ok := order(l, r); ok {
+		return c == 0
+	}
+	lb, lok := l.(bool)
+	rb, rok := r.(bool)
+	if lok && rok {
+		return lb == rb
+	}
+	return isNull(l) && isNull(r)
+}
+
+func isNull(v any) bool {
+	return v == nil
+}
+
+func order(l, r any) (int, bool) {
+	switch lv := l.(type) {
+	case number:
+		rv, ok := r.(number)
+		if !ok {
+			return 0, false
+		}
+		if lv.exact && rv.exact {
+			return cmp.Compare(lv.i, rv.i), true
+		}
+		return cmp.Compare(lv.f, rv.f), true
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(lv, rv), true
+	}
+	return 0, false
+}
@@ -0,0 +1,162 @@
+package expr
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+var testFields = map[string]Type{
+	"name":          String,
+	"size_bytes":    Number,
+	"is_dir":        Bool,
+	"mtime_unix_ns": Number,
+	"io.read_bytes": Number,
+	"argv":          Any,
+	"owner":         String,
+}
+
+// testRecord is the record expressions are matched against; numbers are
+// json.Number as decoded from the JSON output.
+var testRecord = map[string]any{
+	"name":          "main.go",
+	"size_bytes":    json.Number("1500"),
+	"is_dir":        false,
+	"mtime_unix_ns": json.Number("1700000000123456789"),
+	"io.read_bytes": json.Number("2.5e3"),
+	"argv":          []any{"a", "b"},
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`name == "main.go"`, true},
+		{`name == 'main.go'`, true},
+		{`name != "main.go"`, false},
+		{`name < "z"`, true},
+		{`name >= "main.go"`, true},
+		{`size_bytes > 1000`, true},
+		{`size_bytes > 1.5e3`, false},
+		{`size_bytes >= 1.5e3`, true},
+		{`size_bytes == 1500.0`, true},
+		{`size_bytes > -1`, true},
+		{`size_bytes <= .5`, false},
+		{`io.read_bytes == 2500`, true},
+		{`io.read_bytes > 2499.5`, true},
+
+		// Integers beyond 2^53 compare exactly rather than as floats.
+		{`mtime_unix_ns == 1700000000123456789`, true},
+		{`mtime_unix_ns == 1700000000123456788`, false},
+		{`mtime_unix_ns > 1700000000123456788`, true},
+
+		{`is_dir`, false},
+		{`!is_dir`, true},
+		{`is_dir == false`, true},
+		{`!!is_dir`, false},
+
+		{`name =~ "^main\\.go$"`, true},
+		{`name =~ '\.go$'`, true},
+		{`name !~ "_test"`, true},
+		{`name =~ "^java"`, false},
+
+		// && binds tighter than ||.
+		{`is_dir || size_bytes > 1000 && name == "x"`, false},
+		{`(is_dir || size_bytes > 1000) && name == "main.go"`, true},
+		{`is_dir && name == "main.go" || size_bytes == 1500`, true},
+
+		// Missing fields are null: equal to null, unordered, no match.
+		{`owner == null`, true},
+		{`owner != null`, false},
+		{`owner < "a"`, false},
+		{`owner >= "a"`, false},
+		{`owner =~ ""`, false},
+		{`owner !~ "x"`, false},
+		{`argv != null`, true},
+		{`null == null`, true},
+	}
+	get := func(f string) any { return testRecord[f] }
+	for _, tt := range tests {
+		e, err := Parse(tt.expr, testFields)
+		if err != nil {
+			t.Errorf("Parse(%s): %v", tt.expr, err)
+			continue
+		}
+		if got := e.Match(get); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+		msg  string
+	}{
+		// Type mismatches.
+		{`name == 1`, 5, `cannot compare string == number`},
+		{`size_bytes != "1"`, 11, `cannot compare number != string`},
+		{`is_dir < true`, 7, `cannot order bool < bool`},
+		{`size_bytes < "a"`, 11, `cannot order number < string`},
+		{`argv == "a"`, 5, `cannot compare value == string`},
+		{`argv == argv`, 5, `arrays and objects can only be compared with null`},
+		{`size_bytes =~ "1"`, 11, `=~ needs a string operand, got number`},
+		{`size_bytes && is_dir`, 11, `&& needs bool operands, got number and bool`},
+		{`is_dir || name`, 7, `|| needs bool operands, got bool and string`},
+		{`!name`, 0, `! needs a bool operand, got string`},
+		{`name`, 0, `expression is string, not bool`},
+
+		// Patterns must be string literals.
+		{`name =~ owner`, 8, `=~ needs a string pattern, got "owner"`},
+		{`name !~ 1`, 8, `!~ needs a string pattern, got "1"`},
+		{`name =~ "("`, 8, "invalid pattern: error parsing regexp: missing closing ): `(`"},
+
+		// Syntax.
+		{``, 0, `expected operand, got end of expression`},
+		{`name ==`, 7, `expected operand, got end of expression`},
+		{`(is_dir`, 7, `expected ), got end of expression`},
+		{`is_dir)`, 6, `unexpected ")"`},
+		{`is_dir is_dir`, 7, `unexpected "is_dir"`},
+		{`nope == 1`, 0, `unknown field "nope"`},
+		{`size_bytes > - x`, 15, `expected number after -, got "x"`},
+		{`name == "abc`, 8, `unterminated string`},
+		{`name == "\q"`, 8, `invalid string literal "\q"`},
+		{`size_bytes > 1e`, 13, `invalid number 1e`},
+		{`size_bytes > 1 # x`, 15, `unexpected character '#'`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.expr, testFields)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("Parse(%s): err = %v, want a SyntaxError", tt.expr, err)
+			continue
+		}
+		if se.Pos != tt.pos || se.Msg != tt.msg {
+			t.Errorf("Parse(%s): %q at %d, want %q at %d", tt.expr, se.Msg, se.Pos, tt.msg, tt.pos)
+		}
+		if se.Expr != tt.expr {
+			t.Errorf("Parse(%s): Expr = %q", tt.expr, se.Expr)
+		}
+	}
+}
+
+func TestSyntaxErrorJSON(t *testing.T) {
+	_, err := Parse(`size_bytes > "1" && x`, testFields)
+	var se *SyntaxError
+	if !errors.As(err, &se) {
+		t.Fatalf("err = %v, want a SyntaxError", err)
+	}
+	b, jerr := se.MarshalJSON()
+	if jerr != nil {
+		t.Fatal(jerr)
+	}
+	want := `{"error":"cannot order number > string","expression":"size_bytes > \"1\" && x","position":11}`
+	if string(b) != want {
+		t.Errorf("JSON:\n%s\nwant:\n%s", b, want)
+	}
+	if got := err.Error(); got != "where: cannot order number > string at position 11" {
+		t.Errorf("Error() = %q", got)
+	}
+}
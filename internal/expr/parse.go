@@ -0,0 +1,310 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokKind
+	pos  int
+	text string // raw text; unquoted value for strings
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+// Two-character operators must come before their one-character prefixes.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "-"}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && strings.IndexByte(" \t\r\n", l.src[l.pos]) >= 0 {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		return token{kind: tokLParen, pos: start, text: "("}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokRParen, pos: start, text: ")"}, nil
+	case c == '"' || c == '\'':
+		return l.lexString(c)
+	case isDigit(c) || c == '.' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1]):
+		return l.lexNumber()
+	case isIdentStart(c):
+		for l.pos < len(l.src) && (isIdentStart(l.src[l.pos]) || isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		return token{kind: tokIdent, pos: start, text: l.src[start:l.pos]}, nil
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokOp, pos: start, text: op}, nil
+		}
+	}
+	return token{}, &SyntaxError{Expr: l.src, Pos: start, Msg: fmt.Sprintf("unexpected character %q", c)}
+}
+
+// lexString reads a double-quoted string with Go/JSON escapes, or a
+// single-quoted string taken literally.
+func (l *lexer) lexString(q byte) (token, error) {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == '\\' && q == '"' {
+			l.pos += 2
+			continue
+		}
+		l.pos++
+		if c == q {
+			raw := l.src[start:l.pos]
+			if q == '\'' {
+				return token{kind: tokString, pos: start, text: raw[1 : len(raw)-1]}, nil
+			}
+			s, err := strconv.Unquote(raw)
+			if err != nil {
+				return token{}, &SyntaxError{Expr: l.src, Pos: start, Msg: "invalid string literal " + raw}
+			}
+			return token{kind: tokString, pos: start, text: s}, nil
+		}
+	}
+	return token{}, &SyntaxError{Expr: l.src, Pos: start, Msg: "unterminated string"}
+}
+
+func (l *lexer) lexNumber() (token, error) {
+	start := l.pos
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if isDigit(c) || c == '.' || c == 'e' || c == 'E' ||
+			(c == '+' || c == '-') && (l.src[l.pos-1] == 'e' || l.src[l.pos-1] == 'E') {
+			l.pos++
+			continue
+		}
+		break
+	}
+	text := l.src[start:l.pos]
+	if _, err := strconv.ParseFloat(text, 64); err != nil {
+		return token{}, &SyntaxError{Expr: l.src, Pos: start, Msg: "invalid number " + text}
+	}
+	return token{kind: tokNumber, pos: start, text: text}, nil
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+type parser struct {
+	lex    lexer
+	tok    token
+	err    error
+	fields map[string]Type
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lex.next()
+}
+
+func (p *parser) errorf(pos int, format string, args ...any) error {
+	return &SyntaxError{Expr: p.lex.src, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	if p.tok.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	return p.parseLogical("||", p.parseAnd)
+}
+
+func (p *parser) parseAnd() (node, error) {
+	return p.parseLogical("&&", p.parseNot)
+}
+
+func (p *parser) parseLogical(op string, operand func() (node, error)) (node, error) {
+	l, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(op) {
+		pos := p.tok.pos
+		p.next()
+		r, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if l.typ() != Bool || r.typ() != Bool {
+			return nil, p.errorf(pos, "%s needs bool operands, got %s and %s", op, l.typ(), r.typ())
+		}
+		l = &logical{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.isOp("!") {
+		pos := p.tok.pos
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if x.typ() != Bool {
+			return nil, p.errorf(pos, "! needs a bool operand, got %s", x.typ())
+		}
+		return &not{x: x}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	l, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("==", "!=", "<", "<=", ">", ">=", "=~", "!~") {
+		return l, nil
+	}
+	op, pos := p.tok.text, p.tok.pos
+	p.next()
+
+	if op == "=~" || op == "!~" {
+		if p.err != nil {
+			return nil, p.err
+		}
+		if p.tok.kind != tokString {
+			return nil, p.errorf(p.tok.pos, "%s needs a string pattern, got %s", op, p.tok)
+		}
+		re, err := regexp.Compile(p.tok.text)
+		if err != nil {
+			return nil, p.errorf(p.tok.pos, "invalid pattern: %v", err)
+		}
+		p.next()
+		if l.typ() != String {
+			return nil, p.errorf(pos, "%s needs a string operand, got %s", op, l.typ())
+		}
+		return &match{negate: op == "!~", x: l, re: re}, nil
+	}
+
+	r, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	lt, rt := l.typ(), r.typ()
+	switch op {
+	case "==", "!=":
+		if lt != rt && lt != Null && rt != Null {
+			return nil, p.errorf(pos, "cannot compare %s %s %s", lt, op, rt)
+		}
+		if (lt == Any || rt == Any) && lt != Null && rt != Null {
+			return nil, p.errorf(pos, "arrays and objects can only be compared with null")
+		}
+	default:
+		if lt != rt || lt != Number && lt != String {
+			return nil, p.errorf(pos, "cannot order %s %s %s", lt, op, rt)
+		}
+	}
+	return &compare{op: op, l: l, r: r}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	t := p.tok
+	switch t.kind {
+	case tokLParen:
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.err != nil {
+			return nil, p.err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf(p.tok.pos, "expected ), got %s", p.tok)
+		}
+		p.next()
+		return n, nil
+	case tokNumber:
+		p.next()
+		return &literal{t: Number, v: parseNumber(t.text)}, nil
+	case tokString:
+		p.next()
+		return &literal{t: String, v: t.text}, nil
+	case tokOp:
+		if t.text == "-" {
+			p.next()
+			if p.err != nil {
+				return nil, p.err
+			}
+			if p.tok.kind != tokNumber {
+				return nil, p.errorf(p.tok.pos, "expected number after -, got %s", p.tok)
+			}
+			n := &literal{t: Number, v: parseNumber("-" + p.tok.text)}
+			p.next()
+			return n, nil
+		}
+	case tokIdent:
+		p.next()
+		switch t.text {
+		case "true", "false":
+			return &literal{t: Bool, v: t.text == "true"}, nil
+		case "null":
+			return &literal{t: Null, v: nil}, nil
+		}
+		ft, ok := p.fields[t.text]
+		if !ok {
+			return nil, p.errorf(t.pos, "unknown field %q", t.text)
+		}
+		return &field{name: t.text, t: ft}, nil
+	}
+	return nil, p.errorf(t.pos, "expected operand, got %s", t)
+}
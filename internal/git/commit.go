@@ -0,0 +1,271 @@
+package git
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commit is a parsed commit object.
+type Commit struct {
+	ID         ID
+	Tree       ID
+	Parents    []ID
+	Author     string // "Name <email>"
+	AuthorTime time.Time
+	CommitTime time.Time
+}
+
+// TreeEntry is one entry of a tree object.
+type TreeEntry struct {
+	Mode uint32
+	ID   ID
+}
+
+// Tree maps entry names to entries.
+type Tree map[string]TreeEntry
+
+// maxCachedTrees bounds the memory used by decoded trees.
+const maxCachedTrees = 8192
+
+// Commit reads and parses the commit id.
+func (r *Repo) Commit(id ID) (*Commit, error) {
+	r.mu.Lock()
+	c, ok := r.commits[id]
+	r.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+	typ, data, err := r.object(id)
+	if err != nil {
+		return nil, err
+	}
+	if typ != objCommit {
+		return nil, fmt.Errorf("%s: not a commit", id)
+	}
+	c = &Commit{ID: id}
+	hdr, _, _ := bytes.Cut(data, []byte("\n\n"))
+	for _, line := range strings.Split(string(hdr), "\n") {
+		key, val, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			c.Tree, err = ParseID(val)
+		case "parent":
+			var p ID
+			p, err = ParseID(val)
+			c.Parents = append(c.Parents, p)
+		case "author":
+			c.Author, c.AuthorTime = parseSignature(val)
+		case "committer":
+			_, c.CommitTime = parseSignature(val)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", id, err)
+		}
+	}
+
+	r.mu.Lock()
+	if r.commits == nil {
+		r.commits = map[ID]*Commit{}
+	}
+	r.commits[id] = c
+	r.mu.Unlock()
+	return c, nil
+}
+
+// parseSignature splits "Name <email> 1700000000 +0100".
+func parseSignature(s string) (string, time.Time) {
+	end := strings.LastIndexByte(s, '>')
+	if end < 0 {
+		return s, time.Time{}
+	}
+	who := s[:end+1]
+	f := strings.Fields(s[end+1:])
+	if len(f) == 0 {
+		return who, time.Time{}
+	}
+	sec, err := strconv.ParseInt(f[0], 10, 64)
+	if err != nil {
+		return who, time.Time{}
+	}
+	return who, time.Unix(sec, 0).UTC()
+}
+
+// Tree reads and parses the tree id.
+func (r *Repo) Tree(id ID) (Tree, error) {
+	r.mu.Lock()
+	t, ok := r.trees[id]
+	r.mu.Unlock()
+	if ok {
+		return t, nil
+	}
+	typ, data, err := r.object(id)
+	if err != nil {
+		return nil, err
+	}
+	if typ != objTree {
+		return nil, fmt.Errorf("%s: not a tree", id)
+	}
+	// Entries are "<octal mode> <name>\x00<20 byte id>".
+	t = Tree{}
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if sp < 0 || nul < sp || nul+21 > len(data) {
+			return nil, fmt.Errorf("%s: malformed tree", id)
+		}
+		mode, err := strconv.ParseUint(string(data[:sp]), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: malformed tree", id)
+		}
+		var e TreeEntry
+		e.Mode = uint32(mode)
+		copy(e.ID[:], data[nul+1:nul+21])
+		t[string(data[sp+1:nul])] = e
+		data = data[nul+21:]
+	}
+
+	r.mu.Lock()
+	if r.trees == nil || len(r.trees) >= maxCachedTrees {
+		r.trees = map[ID]Tree{}
+	}
+	r.trees[id] = t
+	r.mu.Unlock()
+	return t, nil
+}
+
+// Lookup returns the entry for the slash-separated path below the tree
+// root, or ok false if there is none.
+func (r *Repo) Lookup(root ID, path string) (e TreeEntry, ok bool, err error) {
+	e = TreeEntry{Mode: 0o40000, ID: root}
+	for _, name := range strings.Split(path, "/") {
+		if e.Mode&0o170000 != 0o40000 {
+			return TreeEntry{}, false, nil
+		}
+		t, err := r.Tree(e.ID)
+		if err != nil {
+			return TreeEntry{}, false, err
+		}
+		if e, ok = t[name]; !ok {
+			return TreeEntry{}, false, nil
+		}
+	}
+	return e, true, nil
+}
+
+// Files returns every blob and symlink below the tree root by path.
+func (r *Repo) Files(root ID) (map[string]TreeEntry, error) {
+	files := map[string]TreeEntry{}
+	var walk func(id ID, prefix string) error
+	walk = func(id ID, prefix string) error {
+		t, err := r.Tree(id)
+		if err != nil {
+			return err
+		}
+		for name, e := range t {
+			switch e.Mode & 0o170000 {
+			case 0o40000:
+				if err := walk(e.ID, prefix+name+"/"); err != nil {
+					return err
+				}
+			case 0o160000: // submodule commit
+			default:
+				files[prefix+name] = e
+			}
+		}
+		return nil
+	}
+	return files, walk(root, "")
+}
+
+// LastCommits finds, for each of paths, the newest commit reachable from
+// head that changed it: one whose entry for the path differs from that of
+// every parent, or a root commit that has it. Paths must exist at head.
+// History is walked newest first by commit time and simplified per path as
+// git log does: past a merge whose entry for the path equals that of one of
+// its parents, only the first such parent is followed for that path.
+func (r *Repo) LastCommits(head ID, paths []string) (map[string]*Commit, error) {
+	found := map[string]*Commit{}
+	if head.IsZero() || len(paths) == 0 {
+		return found, nil
+	}
+	pending := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		pending[p] = true
+	}
+
+	c, err := r.Commit(head)
+	if err != nil {
+		return nil, err
+	}
+	// waiting holds the paths still followed through each queued commit.
+	waiting := map[ID][]string{head: paths}
+	q := &commitQueue{c}
+	for q.Len() > 0 && len(pending) > 0 {
+		c := heap.Pop(q).(*Commit)
+		follow := waiting[c.ID]
+		delete(waiting, c.ID)
+
+		parents := make([]*Commit, 0, len(c.Parents))
+		for _, pid := range c.Parents {
+			p, err := r.Commit(pid)
+			if errors.Is(err, errNotFound) {
+				continue // history cut off by a shallow clone
+			}
+			if err != nil {
+				return nil, err
+			}
+			parents = append(parents, p)
+		}
+
+		for _, path := range follow {
+			if !pending[path] {
+				continue
+			}
+			e, ok, err := r.Lookup(c.Tree, path)
+			if err != nil {
+				return nil, err
+			}
+			var same *Commit
+			for _, p := range parents {
+				pe, pok, err := r.Lookup(p.Tree, path)
+				if err != nil {
+					return nil, err
+				}
+				if pok == ok && pe == e {
+					same = p
+					break
+				}
+			}
+			switch {
+			case same != nil:
+				if _, queued := waiting[same.ID]; !queued {
+					heap.Push(q, same)
+				}
+				waiting[same.ID] = append(waiting[same.ID], path)
+			case ok || len(parents) > 0:
+				found[path] = c
+				delete(pending, path)
+			}
+		}
+	}
+	return found, nil
+}
+
+// commitQueue is a max-heap of commits by commit time.
+type commitQueue []*Commit
+
+func (q commitQueue) Len() int           { return len(q) }
+func (q commitQueue) Less(i, j int) bool { return q[i].CommitTime.After(q[j].CommitTime) }
+func (q commitQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x any)        { *q = append(*q, x.(*Commit)) }
+func (q *commitQueue) Pop() any {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
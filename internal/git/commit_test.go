@@ -0,0 +1,98 @@
+package git
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLastCommitsMatchesGitLog(t *testing.T) {
+	tr := newTestRepo(t)
+	tr.write("README", "v1\n")
+	tr.write("src/a.go", "a1\n")
+	tr.write("src/b.go", "b1\n")
+	tr.write("docs/guide.md", "g1\n")
+	tr.write("x", "x1\n")
+	tr.commit("initial")
+
+	tr.write("src/a.go", "a2\n")
+	tr.commit("edit a")
+
+	// A side branch edits b; the merge itself changes nothing new.
+	tr.git("checkout", "-q", "-b", "side")
+	tr.write("src/b.go", "b2\n")
+	tr.commit("edit b on side")
+	tr.git("checkout", "-q", "main")
+	tr.write("docs/guide.md", "g2\n")
+	tr.commit("edit guide")
+	tr.git("merge", "-q", "--no-ff", "-m", "merge side", "side")
+
+	// Reverting a file to older contents is still a change.
+	tr.write("src/a.go", "a1\n")
+	tr.commit("revert a")
+	tr.write("src/c.go", "c1\n")
+	tr.commit("add c")
+
+	// A merge whose contents differ from both parents changes the file.
+	tr.git("checkout", "-q", "-b", "other", "HEAD~1")
+	tr.write("README", "other\n")
+	tr.commit("readme on other")
+	tr.git("checkout", "-q", "main")
+	tr.write("README", "main\n")
+	tr.commit("readme on main")
+	tr.git("merge", "-q", "-s", "ours", "-m", "merge other", "other")
+	tr.write("README", "merged\n")
+	tr.git("commit", "-q", "-a", "--amend", "-m", "merge other")
+
+	// A merge that keeps main's x discards the side branch's newer edit of
+	// it: git log follows only the parent x came from.
+	tr.git("checkout", "-q", "-b", "discarded")
+	tr.write("x", "x2\n")
+	tr.commit("edit x on discarded")
+	tr.git("checkout", "-q", "main")
+	tr.write("src/c.go", "c2\n")
+	tr.commit("edit c")
+	tr.git("merge", "-q", "-s", "ours", "-m", "merge discarded", "discarded")
+
+	head := tr.id("HEAD")
+	paths := strings.Split(tr.git("ls-tree", "-r", "--name-only", "HEAD"), "\n")
+	for _, packed := range []bool{false, true} {
+		if packed {
+			repack(tr, true)
+		}
+		got, err := tr.open().LastCommits(head, paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range paths {
+			want := tr.git("log", "-1", "--format=%H", "--", path)
+			c, ok := got[path]
+			switch {
+			case !ok:
+				t.Errorf("%s (packed %v): no commit found, want %s", path, packed, want)
+			case c.ID.String() != want:
+				t.Errorf("%s (packed %v): commit %s, want %s (git log -1)", path, packed, c.ID, want)
+			}
+		}
+	}
+
+	r := tr.open()
+	c, err := r.Commit(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Author != "Test <test@example.com>" || len(c.Parents) != 2 {
+		t.Errorf("HEAD: author %q, %d parents", c.Author, len(c.Parents))
+	}
+	if want := tr.git("log", "-1", "--format=%ct"); c.CommitTime.Unix() == 0 || want != strconv.FormatInt(c.CommitTime.Unix(), 10) {
+		t.Errorf("HEAD: commit time %d, want %s", c.CommitTime.Unix(), want)
+	}
+}
+
+func TestLastCommitsEmpty(t *testing.T) {
+	r := &Repo{}
+	got, err := r.LastCommits(ID{}, []string{"a"})
+	if err != nil || len(got) != 0 {
+		t.Errorf("LastCommits on an unborn branch = %v, %v", got, err)
+	}
+}
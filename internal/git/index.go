@@ -0,0 +1,218 @@
+package git
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IndexEntry is a file in the index (staging area).
+type IndexEntry struct {
+	Path  string // slash-separated, relative to the work tree
+	Mode  uint32
+	ID    ID
+	Size  uint32 // low 32 bits of the file size when staged
+	Mtime int64  // seconds
+	Mnsec uint32
+	Stage int // non-zero for unmerged entries
+}
+
+// Index is a parsed index file, with entries sorted by path.
+type Index struct {
+	Entries []IndexEntry
+	// Mtime is the modification time of the index file itself, used to
+	// detect files changed in the same second they were staged.
+	Mtime int64
+}
+
+// Index reads the repository's index. A missing index is empty.
+func (r *Repo) Index() (*Index, error) {
+	path := filepath.Join(r.GitDir, "index")
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Index{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	idx, err := parseIndex(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if fi, err := os.Stat(path); err == nil {
+		idx.Mtime = fi.ModTime().Unix()
+	}
+	return idx, nil
+}
+
+// parseIndex decodes index versions 2, 3 and 4; extensions are skipped.
+func parseIndex(b []byte) (*Index, error) {
+	bad := fmt.Errorf("malformed index")
+	if len(b) < 12 || string(b[:4]) != "DIRC" {
+		return nil, bad
+	}
+	version := binary.BigEndian.Uint32(b[4:])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("unsupported index version %d", version)
+	}
+	n := int(binary.BigEndian.Uint32(b[8:]))
+	idx := &Index{Entries: make([]IndexEntry, 0, n)}
+	at := 12
+	prev := ""
+	for i := 0; i < n; i++ {
+		start := at
+		if at+62 > len(b) {
+			return nil, bad
+		}
+		e := IndexEntry{
+			Mtime: int64(binary.BigEndian.Uint32(b[at+8:])),
+			Mnsec: binary.BigEndian.Uint32(b[at+12:]),
+			Mode:  binary.BigEndian.Uint32(b[at+24:]),
+			Size:  binary.BigEndian.Uint32(b[at+36:]),
+		}
+		copy(e.ID[:], b[at+40:at+60])
+		flags := binary.BigEndian.Uint16(b[at+60:])
+		e.Stage = int(flags>>12) & 3
+		at += 62
+		if flags&0x4000 != 0 { // extended flags (version 3+)
+			at += 2
+		}
+
+		if version == 4 {
+			// The name is the previous name minus a varint-encoded number
+			// of trailing bytes, plus a NUL-terminated suffix.
+			strip, w := offsetVarint(b[at:])
+			if w == 0 || strip > len(prev) {
+				return nil, bad
+			}
+			at += w
+			end := bytes.IndexByte(b[at:], 0)
+			if end < 0 {
+				return nil, bad
+			}
+			e.Path = prev[:len(prev)-strip] + string(b[at:at+end])
+			at += end + 1
+		} else {
+			end := bytes.IndexByte(b[at:], 0)
+			if end < 0 {
+				return nil, bad
+			}
+			e.Path = string(b[at : at+end])
+			// Entries are NUL-padded to a multiple of eight bytes.
+			at = start + (at-start+end+8)&^7
+		}
+		prev = e.Path
+		idx.Entries = append(idx.Entries, e)
+	}
+	return idx, nil
+}
+
+// offsetVarint decodes the variable-length integer of index version 4,
+// which adds one per continuation byte like pack OFS_DELTA offsets.
+func offsetVarint(b []byte) (int, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	c := b[0]
+	n := int(c & 0x7f)
+	i := 1
+	for c&0x80 != 0 {
+		if i >= len(b) {
+			return 0, 0
+		}
+		c = b[i]
+		i++
+		n = (n+1)<<7 | int(c&0x7f)
+	}
+	return n, i
+}
+
+// Find returns the stage 0 entry for path, or the first unmerged one.
+func (idx *Index) Find(path string) (*IndexEntry, bool) {
+	i := sort.Search(len(idx.Entries), func(i int) bool { return idx.Entries[i].Path >= path })
+	if i < len(idx.Entries) && idx.Entries[i].Path == path {
+		return &idx.Entries[i], true
+	}
+	return nil, false
+}
+
+// Under returns the entries below the directory dir.
+func (idx *Index) Under(dir string) []IndexEntry {
+	prefix := dir + "/"
+	i := sort.Search(len(idx.Entries), func(i int) bool { return idx.Entries[i].Path >= prefix })
+	j := i
+	for j < len(idx.Entries) && strings.HasPrefix(idx.Entries[j].Path, prefix) {
+		j++
+	}
+	return idx.Entries[i:j]
+}
+
+// HashFile returns the blob ID the file at path would have if staged:
+// its contents, or the link target for a symlink.
+func HashFile(path string, info os.FileInfo) (ID, error) {
+	var id ID
+	h := sha1.New()
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return id, err
+		}
+		h.Write([]byte("blob " + strconv.Itoa(len(target)) + "\x00" + target))
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return id, err
+		}
+		defer f.Close()
+		h.Write([]byte("blob " + strconv.FormatInt(info.Size(), 10) + "\x00"))
+		if _, err := io.Copy(h, f); err != nil {
+			return id, err
+		}
+	}
+	h.Sum(id[:0])
+	return id, nil
+}
+
+// Modified reports whether the work tree file at path, described by info
+// from lstat, differs from the entry. Unchanged size and mtime are taken to
+// mean unchanged contents, as git does, unless the file was modified in the
+// same second the index was written.
+func (idx *Index) Modified(e *IndexEntry, path string, info os.FileInfo) (bool, error) {
+	if e.Stage != 0 {
+		return true, nil
+	}
+	switch {
+	case e.Mode&0o170000 == 0o120000:
+		if info.Mode()&os.ModeSymlink == 0 {
+			return true, nil
+		}
+	case e.Mode&0o170000 == 0o160000: // submodule: not inspected
+		return false, nil
+	default:
+		if !info.Mode().IsRegular() {
+			return true, nil
+		}
+		if (e.Mode&0o111 != 0) != (info.Mode()&0o111 != 0) {
+			return true, nil
+		}
+	}
+	if uint32(info.Size()) != e.Size {
+		return true, nil
+	}
+	mt := info.ModTime()
+	if mt.Unix() == e.Mtime && uint32(mt.Nanosecond()) == e.Mnsec && e.Mtime < idx.Mtime {
+		return false, nil
+	}
+	id, err := HashFile(path, info)
+	if err != nil {
+		return false, err
+	}
+	return id != e.ID, nil
+}
@@ -0,0 +1,135 @@
+package git
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIndexVersions(t *testing.T) {
+	for _, version := range []string{"2", "3", "4"} {
+		t.Run("v"+version, func(t *testing.T) {
+			tr := newTestRepo(t)
+			// Paths sharing long prefixes, which version 4 compresses.
+			for _, name := range []string{
+				"README", "src/main.go", "src/main_test.go", "src/pkg/a.go",
+				"src/pkg/aa.go", "src/pkg/ab/x.go", "zzz",
+			} {
+				tr.write(name, name+"\n")
+			}
+			tr.git("add", "-A")
+			if version == "3" {
+				// Version 3 adds extended flags, such as intent-to-add.
+				tr.write("src/new.go", "new\n")
+				tr.git("add", "--intent-to-add", "src/new.go")
+			}
+			tr.git("update-index", "--index-version", version)
+			b, err := os.ReadFile(filepath.Join(tr.dir, ".git", "index"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v := binary.BigEndian.Uint32(b[4:]); strconv.Itoa(int(v)) != version {
+				t.Fatalf("index version %d, want %s", v, version)
+			}
+
+			idx, err := tr.open().Index()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range idx.Entries {
+				got = append(got, e.ID.String()+" "+e.Path)
+			}
+			var want []string
+			for _, line := range strings.Split(tr.git("ls-files", "-s"), "\n") {
+				// "<mode> <id> <stage>\t<path>"
+				f := strings.Fields(line)
+				want = append(want, f[1]+" "+f[3])
+			}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("entries:\n%s\nwant (git ls-files -s):\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+// v4Entry is the name of a version 4 index entry: the varint-encoded
+// number of bytes stripped from the previous name and the suffix appended.
+type v4Entry struct {
+	strip  []byte
+	suffix string
+}
+
+// indexV4 builds a version 4 index with the given entry names.
+func indexV4(entries ...v4Entry) []byte {
+	var b bytes.Buffer
+	b.WriteString("DIRC")
+	binary.Write(&b, binary.BigEndian, uint32(4))
+	binary.Write(&b, binary.BigEndian, uint32(len(entries)))
+	for i, e := range entries {
+		var fixed [62]byte
+		binary.BigEndian.PutUint32(fixed[24:], 0o100644)
+		fixed[40] = byte(i + 1) // object ID
+		binary.BigEndian.PutUint16(fixed[60:], uint16(len(e.suffix)))
+		b.Write(fixed[:])
+		b.Write(e.strip)
+		b.WriteString(e.suffix)
+		b.WriteByte(0)
+	}
+	return b.Bytes()
+}
+
+func TestParseIndexV4PrefixCompression(t *testing.T) {
+	// A 131 byte name needs a two byte varint to strip all of it.
+	long := strings.Repeat("d", 129) + "/"
+	b := indexV4(
+		v4Entry{[]byte{0}, "dir/file_a"},
+		v4Entry{[]byte{1}, "b"},          // dir/file_b
+		v4Entry{[]byte{6}, "sub/x"},      // dir/sub/x
+		v4Entry{[]byte{9}, long + "y"},   // replace everything
+		v4Entry{[]byte{0x80, 0x03}, "z"}, // strip (0+1)<<7|3 = 131 bytes
+	)
+	idx, err := parseIndex(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"dir/file_a", "dir/file_b", "dir/sub/x", long + "y", "z"}
+	if len(idx.Entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(idx.Entries), len(want))
+	}
+	for i, e := range idx.Entries {
+		if e.Path != want[i] {
+			t.Errorf("entry %d: path %q, want %q", i, e.Path, want[i])
+		}
+		if e.ID[0] != byte(i+1) || e.Mode != 0o100644 {
+			t.Errorf("entry %d: id %s mode %o", i, e.ID, e.Mode)
+		}
+	}
+}
+
+func TestParseIndexMalformed(t *testing.T) {
+	valid := indexV4(v4Entry{[]byte{0}, "a"}, v4Entry{[]byte{1}, "b"})
+	tests := map[string][]byte{
+		"empty":              nil,
+		"bad signature":      append([]byte("CRID"), valid[4:]...),
+		"truncated entry":    valid[:40],
+		"missing NUL":        valid[:len(valid)-1],
+		"strip beyond name":  indexV4(v4Entry{[]byte{0}, "a"}, v4Entry{[]byte{5}, "b"}),
+		"unterminated strip": indexV4(v4Entry{[]byte{0x80}, ""})[:12+62+1],
+	}
+	for name, b := range tests {
+		if _, err := parseIndex(b); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+
+	unsupported := append([]byte(nil), valid...)
+	binary.BigEndian.PutUint32(unsupported[4:], 5)
+	if _, err := parseIndex(unsupported); err == nil || !strings.Contains(err.Error(), "version 5") {
+		t.Errorf("version 5: err = %v", err)
+	}
+}
@@ -0,0 +1,402 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Object types as numbered in pack files.
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
+)
+
+var typeNames = map[string]int{"commit": objCommit, "tree": objTree, "blob": objBlob, "tag": objTag}
+
+// errNotFound is returned for objects that are neither loose nor packed.
+var errNotFound = errors.New("object not found")
+
+// object returns the type and contents of the object id.
+func (r *Repo) object(id ID) (int, []byte, error) {
+	typ, data, err := r.looseObject(id)
+	if !os.IsNotExist(err) {
+		return typ, data, err
+	}
+	packs, err := r.loadPacks()
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, p := range packs {
+		if off, ok := p.find(id); ok {
+			return p.read(r, off)
+		}
+	}
+	return 0, nil, fmt.Errorf("%s: %w", id, errNotFound)
+}
+
+func (r *Repo) looseObject(id ID) (int, []byte, error) {
+	s := id.String()
+	f, err := os.Open(filepath.Join(r.CommonDir, "objects", s[:2], s[2:]))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", id, err)
+	}
+	defer zr.Close()
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", id, err)
+	}
+	// "<type> <size>\x00<data>"
+	hdr, data, ok := bytes.Cut(b, []byte{0})
+	typeName, size, ok2 := bytes.Cut(hdr, []byte(" "))
+	typ := typeNames[string(typeName)]
+	if !ok || !ok2 || typ == 0 || strconv.Itoa(len(data)) != string(size) {
+		return 0, nil, fmt.Errorf("%s: malformed loose object", id)
+	}
+	return typ, data, nil
+}
+
+// pack is a pack file with its version 1 or 2 index.
+type pack struct {
+	path    string
+	fanout  [256]uint32
+	ids     []byte // sorted object names, 20 bytes each
+	offsets func(i int) int64
+
+	file  *os.File
+	cache map[int64]cachedObject // recently inflated delta bases
+}
+
+type cachedObject struct {
+	typ  int
+	data []byte
+}
+
+func (r *Repo) loadPacks() ([]*pack, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.loaded {
+		return r.packs, r.packsErr
+	}
+	r.loaded = true
+	idxs, _ := filepath.Glob(filepath.Join(r.CommonDir, "objects", "pack", "*.idx"))
+	for _, idx := range idxs {
+		p, err := openPack(idx)
+		if err != nil {
+			r.packsErr = err
+			return nil, err
+		}
+		r.packs = append(r.packs, p)
+	}
+	return r.packs, nil
+}
+
+// Close closes the pack files opened to read objects. They are opened
+// again if r is used afterwards.
+func (r *Repo) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var firstErr error
+	for _, p := range r.packs {
+		if p.file == nil {
+			continue
+		}
+		if err := p.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		p.file = nil
+	}
+	return firstErr
+}
+
+func openPack(idxPath string) (*pack, error) {
+	b, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	p := &pack{path: idxPath[:len(idxPath)-len(".idx")] + ".pack", cache: map[int64]cachedObject{}}
+	bad := fmt.Errorf("%s: malformed pack index", idxPath)
+
+	if bytes.HasPrefix(b, []byte("\xfftOc")) {
+		if len(b) < 8+256*4 || binary.BigEndian.Uint32(b[4:]) != 2 {
+			return nil, bad
+		}
+		for i := range p.fanout {
+			p.fanout[i] = binary.BigEndian.Uint32(b[8+i*4:])
+		}
+		n := int(p.fanout[255])
+		idsAt := 8 + 256*4
+		offAt := idsAt + n*20 + n*4 // skip the CRC table
+		bigAt := offAt + n*4
+		if len(b) < bigAt {
+			return nil, bad
+		}
+		p.ids = b[idsAt : idsAt+n*20]
+		p.offsets = func(i int) int64 {
+			off := binary.BigEndian.Uint32(b[offAt+i*4:])
+			if off&0x80000000 == 0 {
+				return int64(off)
+			}
+			at := bigAt + int(off&0x7fffffff)*8
+			if at+8 > len(b) {
+				return -1
+			}
+			return int64(binary.BigEndian.Uint64(b[at:]))
+		}
+		return p, nil
+	}
+
+	// Version 1: fanout, then (offset, name) pairs.
+	if len(b) < 256*4 {
+		return nil, bad
+	}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(b[i*4:])
+	}
+	n := int(p.fanout[255])
+	if len(b) < 256*4+n*24 {
+		return nil, bad
+	}
+	ids := make([]byte, 0, n*20)
+	for i := 0; i < n; i++ {
+		at := 256*4 + i*24
+		ids = append(ids, b[at+4:at+24]...)
+	}
+	p.ids = ids
+	p.offsets = func(i int) int64 {
+		return int64(binary.BigEndian.Uint32(b[256*4+i*24:]))
+	}
+	return p, nil
+}
+
+// find returns the offset of id in the pack file.
+func (p *pack) find(id ID) (int64, bool) {
+	lo := 0
+	if id[0] > 0 {
+		lo = int(p.fanout[id[0]-1])
+	}
+	hi := int(p.fanout[id[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.ids[(lo+i)*20:(lo+i+1)*20], id[:]) >= 0
+	})
+	if i < hi && bytes.Equal(p.ids[i*20:(i+1)*20], id[:]) {
+		off := p.offsets(i)
+		return off, off >= 0
+	}
+	return 0, false
+}
+
+// read inflates the object at off, applying deltas.
+func (p *pack) read(r *Repo, off int64) (int, []byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p.file == nil {
+		f, err := os.Open(p.path)
+		if err != nil {
+			return 0, nil, err
+		}
+		p.file = f
+	}
+	return p.readAt(r, off, 0)
+}
+
+const maxDeltaChain = 10000
+
+func (p *pack) readAt(r *Repo, off int64, depth int) (int, []byte, error) {
+	if c, ok := p.cache[off]; ok {
+		return c.typ, c.data, nil
+	}
+	if depth > maxDeltaChain {
+		return 0, nil, fmt.Errorf("%s: delta chain too long", p.path)
+	}
+	br := bufio.NewReader(io.NewSectionReader(p.file, off, 1<<62))
+
+	// Type and inflated size: 3 type bits, then a little-endian varint.
+	c, err := br.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	typ := int(c>>4) & 7
+	size := int64(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = br.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= int64(c&0x7f) << shift
+	}
+
+	var baseOff int64 = -1
+	var baseID ID
+	switch typ {
+	case objOfsDelta:
+		// Big-endian offset back from this object, with an implicit +1
+		// per continuation byte.
+		c, err := br.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		back := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = br.ReadByte(); err != nil {
+				return 0, nil, err
+			}
+			back = (back+1)<<7 | int64(c&0x7f)
+		}
+		baseOff = off - back
+	case objRefDelta:
+		if _, err := io.ReadFull(br, baseID[:]); err != nil {
+			return 0, nil, err
+		}
+	case objCommit, objTree, objBlob, objTag:
+	default:
+		return 0, nil, fmt.Errorf("%s: unknown object type %d at %d", p.path, typ, off)
+	}
+
+	zr, err := zlib.NewReader(br)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", p.path, err)
+	}
+	data := make([]byte, size)
+	_, err = io.ReadFull(zr, data)
+	zr.Close()
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", p.path, err)
+	}
+	if typ != objOfsDelta && typ != objRefDelta {
+		return typ, data, nil
+	}
+
+	var baseType int
+	var base []byte
+	if baseOff >= 0 {
+		baseType, base, err = p.readAt(r, baseOff, depth+1)
+	} else {
+		// The base may live in another pack or be loose; the lock is
+		// held, so look it up without re-entering read.
+		baseType, base, err = r.objectLocked(baseID, depth+1)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	out, err := applyDelta(base, data)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", p.path, err)
+	}
+	p.remember(off, baseType, out)
+	return baseType, out, nil
+}
+
+// remember caches a reconstructed object, as neighbouring deltas are often
+// based on it.
+func (p *pack) remember(off int64, typ int, data []byte) {
+	if len(p.cache) >= 256 {
+		clear(p.cache)
+	}
+	p.cache[off] = cachedObject{typ, data}
+}
+
+// objectLocked is object for callers already holding r.mu.
+func (r *Repo) objectLocked(id ID, depth int) (int, []byte, error) {
+	typ, data, err := r.looseObject(id)
+	if !os.IsNotExist(err) {
+		return typ, data, err
+	}
+	for _, p := range r.packs {
+		if off, ok := p.find(id); ok {
+			if p.file == nil {
+				f, err := os.Open(p.path)
+				if err != nil {
+					return 0, nil, err
+				}
+				p.file = f
+			}
+			return p.readAt(r, off, depth)
+		}
+	}
+	return 0, nil, fmt.Errorf("%s: %w", id, errNotFound)
+}
+
+// applyDelta rebuilds an object from its base and a git delta: the base
+// and result sizes, then copy-from-base and insert instructions.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	bad := errors.New("malformed delta")
+	varint := func() (int, bool) {
+		n, shift := 0, 0
+		for len(delta) > 0 {
+			c := delta[0]
+			delta = delta[1:]
+			n |= int(c&0x7f) << shift
+			if c&0x80 == 0 {
+				return n, true
+			}
+			shift += 7
+		}
+		return 0, false
+	}
+	srcSize, ok1 := varint()
+	dstSize, ok2 := varint()
+	if !ok1 || !ok2 || srcSize != len(base) {
+		return nil, bad
+	}
+	out := make([]byte, 0, dstSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		if op&0x80 == 0 {
+			n := int(op)
+			if n == 0 || n > len(delta) {
+				return nil, bad
+			}
+			out = append(out, delta[:n]...)
+			delta = delta[n:]
+			continue
+		}
+		var off, n int
+		for i := 0; i < 4; i++ {
+			if op&(1<<i) != 0 {
+				if len(delta) == 0 {
+					return nil, bad
+				}
+				off |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		for i := 0; i < 3; i++ {
+			if op&(0x10<<i) != 0 {
+				if len(delta) == 0 {
+					return nil, bad
+				}
+				n |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		if n == 0 {
+			n = 0x10000
+		}
+		if off+n > len(base) {
+			return nil, bad
+		}
+		out = append(out, base[off:off+n]...)
+	}
+	if len(out) != dstSize {
+		return nil, bad
+	}
+	return out, nil
+}
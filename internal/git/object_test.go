@@ -0,0 +1,220 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// deltaHistory commits many small edits of one large file, so that a
+// repack stores its versions as chains of deltas.
+func deltaHistory(t *testing.T) *testRepo {
+	tr := newTestRepo(t)
+	var lines []string
+	for i := 0; i < 400; i++ {
+		lines = append(lines, fmt.Sprintf("line %d of a file that changes a little in every commit", i))
+	}
+	for rev := 0; rev < 12; rev++ {
+		lines[rev*30] = fmt.Sprintf("edited in revision %d", rev)
+		tr.write("big.txt", strings.Join(lines, "\n")+"\n")
+		tr.write(fmt.Sprintf("small/%d.txt", rev), "small\n")
+		tr.commit(fmt.Sprintf("revision %d", rev))
+	}
+	return tr
+}
+
+// repack replaces every object with a single pack written by
+// git pack-objects, with OFS_DELTA or REF_DELTA entries.
+func repack(tr *testRepo, ofs bool) {
+	tr.t.Helper()
+	tr.git("repack", "-a", "-d", "-q")
+	tr.git("prune-packed")
+	objects := tr.git("rev-list", "--objects", "--all")
+	packDir := filepath.Join(tr.dir, ".git", "objects", "pack")
+	old, _ := filepath.Glob(filepath.Join(packDir, "pack-*"))
+
+	args := []string{"pack-objects", "-q", "--window=50", "--depth=50"}
+	if ofs {
+		args = append(args, "--delta-base-offset")
+	}
+	cmd := exec.Command("git", append(args, filepath.Join(packDir, "test"))...)
+	cmd.Dir = tr.dir
+	cmd.Stdin = strings.NewReader(objects + "\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		tr.t.Fatalf("git pack-objects: %v\n%s", err, out)
+	}
+	for _, f := range old {
+		os.Remove(f)
+	}
+}
+
+// deltaTypes counts the OFS_DELTA and REF_DELTA entries of the repository's
+// packs, and returns the longest delta chain git reports.
+func deltaTypes(t *testing.T, tr *testRepo, r *Repo) (ofs, ref, depth int) {
+	packs, err := r.loadPacks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range packs {
+		f, err := os.Open(p.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(p.ids)/20; i++ {
+			var c [1]byte
+			if _, err := f.ReadAt(c[:], p.offsets(i)); err != nil {
+				t.Fatal(err)
+			}
+			switch int(c[0]>>4) & 7 {
+			case objOfsDelta:
+				ofs++
+			case objRefDelta:
+				ref++
+			}
+		}
+		f.Close()
+
+		// "chain length = N: M objects" lines of git verify-pack -v.
+		for _, line := range strings.Split(tr.git("verify-pack", "-v", p.path), "\n") {
+			var n, m int
+			if _, err := fmt.Sscanf(line, "chain length = %d: %d object", &n, &m); err == nil && n > depth {
+				depth = n
+			}
+		}
+	}
+	return ofs, ref, depth
+}
+
+func TestPackDeltaChains(t *testing.T) {
+	for _, ofs := range []bool{true, false} {
+		name := "ref_delta"
+		if ofs {
+			name = "ofs_delta"
+		}
+		t.Run(name, func(t *testing.T) {
+			tr := deltaHistory(t)
+			repack(tr, ofs)
+			r := tr.open()
+
+			nOfs, nRef, depth := deltaTypes(t, tr, r)
+			if ofs && (nOfs == 0 || nRef != 0) || !ofs && (nRef == 0 || nOfs != 0) {
+				t.Fatalf("pack has %d OFS_DELTA and %d REF_DELTA entries", nOfs, nRef)
+			}
+			if depth < 2 {
+				t.Fatalf("longest delta chain %d, want a chain of at least 2", depth)
+			}
+			if loose, _ := filepath.Glob(filepath.Join(tr.dir, ".git", "objects", "??", "*")); len(loose) > 0 {
+				t.Fatalf("%d loose objects left", len(loose))
+			}
+
+			for _, line := range strings.Split(tr.git("rev-list", "--objects", "--all"), "\n") {
+				hexID, _, _ := strings.Cut(line, " ")
+				id, err := ParseID(hexID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				typ, data, err := r.object(id)
+				if err != nil {
+					t.Fatalf("%s: %v", id, err)
+				}
+				wantType := tr.git("cat-file", "-t", hexID)
+				if typeNames[wantType] != typ {
+					t.Errorf("%s: type %d, want %s", id, typ, wantType)
+				}
+				want := catFile(t, tr, wantType, hexID)
+				if !bytes.Equal(data, want) {
+					t.Errorf("%s: contents differ from git cat-file (%d vs %d bytes)", id, len(data), len(want))
+				}
+			}
+		})
+	}
+}
+
+func TestCloseReopensPacks(t *testing.T) {
+	tr := deltaHistory(t)
+	repack(tr, true)
+	r := tr.open()
+	head := tr.id("HEAD")
+	if _, err := r.Commit(head); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range r.packs {
+		if p.file != nil {
+			t.Errorf("%s still open after Close", p.path)
+		}
+	}
+	c, err := r.Commit(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Files(c.Tree); err != nil {
+		t.Errorf("reading after Close: %v", err)
+	}
+}
+
+// catFile returns the raw contents of an object, without trimming.
+func catFile(t *testing.T, tr *testRepo, typ, id string) []byte {
+	cmd := exec.Command("git", "cat-file", typ, id)
+	cmd.Dir = tr.dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git cat-file %s %s: %v", typ, id, err)
+	}
+	return out
+}
+
+func TestLooseObjects(t *testing.T) {
+	tr := newTestRepo(t)
+	tr.write("a.txt", "hello\n")
+	head := tr.commit("loose")
+	r := tr.open()
+	typ, data, err := r.object(head)
+	if err != nil || typ != objCommit {
+		t.Fatalf("object(HEAD) = %d, %v", typ, err)
+	}
+	if want := catFile(t, tr, "commit", head.String()); !bytes.Equal(data, want) {
+		t.Errorf("commit contents:\n%s\nwant:\n%s", data, want)
+	}
+	if _, _, err := r.object(ID{1}); err == nil {
+		t.Error("no error for a missing object")
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("0123456789abcdef")
+	tests := []struct {
+		name  string
+		delta []byte
+		want  string // empty for malformed deltas
+	}{
+		// Copy 4 bytes at offset 2, insert "XY", copy 2 bytes at offset 14.
+		{"copy and insert", []byte{16, 8, 0x91, 2, 4, 2, 'X', 'Y', 0x91, 14, 2}, "2345XYef"},
+		// A copy without size bytes copies 0x10000, more than the base.
+		{"implicit copy size", []byte{16, 16, 0x80}, ""},
+		{"wrong base size", []byte{15, 2, 2, 'a', 'b'}, ""},
+		{"copy past base", []byte{16, 4, 0x91, 14, 4}, ""},
+		{"insert past delta", []byte{16, 4, 4, 'a', 'b'}, ""},
+		{"zero insert", []byte{16, 0, 0}, ""},
+		{"wrong result size", []byte{16, 3, 2, 'a', 'b'}, ""},
+		{"truncated copy", []byte{16, 4, 0x91, 2}, ""},
+		{"truncated size", []byte{0x80}, ""},
+	}
+	for _, tt := range tests {
+		got, err := applyDelta(base, tt.delta)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%s: no error, got %q", tt.name, got)
+		case tt.want != "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.want != "" && string(got) != tt.want:
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
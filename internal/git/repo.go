@@ -0,0 +1,184 @@
+// Package git reads git repositories directly from the .git directory, so
+// that jout can annotate files without a git binary. It understands loose
+// and packed objects, refs, packed-refs and the index, and is read-only.
+// Only SHA-1 repositories are supported.
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNotRepository is returned by Discover for paths outside a work tree.
+var ErrNotRepository = errors.New("not a git repository")
+
+// ID is a SHA-1 object name.
+type ID [20]byte
+
+func (id ID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsZero reports whether id is the all-zero ID, used for missing objects.
+func (id ID) IsZero() bool { return id == ID{} }
+
+// ParseID parses a 40 character hex object name.
+func ParseID(s string) (ID, error) {
+	var id ID
+	if len(s) != 2*len(id) {
+		return id, fmt.Errorf("invalid object name %q", s)
+	}
+	if _, err := hex.Decode(id[:], []byte(s)); err != nil {
+		return id, fmt.Errorf("invalid object name %q", s)
+	}
+	return id, nil
+}
+
+// Repo is a repository opened for reading.
+type Repo struct {
+	// WorkTree is the top directory of the working tree.
+	WorkTree string
+	// GitDir holds HEAD and the index; CommonDir holds objects and refs.
+	// They differ for linked worktrees.
+	GitDir    string
+	CommonDir string
+
+	mu       sync.Mutex
+	packs    []*pack
+	packsErr error
+	loaded   bool
+	commits  map[ID]*Commit
+	trees    map[ID]Tree
+}
+
+// Discover finds the repository whose work tree contains path.
+func Discover(path string) (*Repo, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if r, err := open(dir); err == nil {
+			return r, nil
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, ErrNotRepository
+		}
+		dir = parent
+	}
+}
+
+// open opens the repository whose work tree is dir, following a .git file
+// ("gitdir: ...") as used by linked worktrees and submodules.
+func open(dir string) (*Repo, error) {
+	gitDir := filepath.Join(dir, ".git")
+	fi, err := os.Stat(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		b, err := os.ReadFile(gitDir)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := strings.CutPrefix(strings.TrimSpace(string(b)), "gitdir:")
+		if !ok {
+			return nil, fmt.Errorf("%s: invalid gitdir file", gitDir)
+		}
+		gitDir = strings.TrimSpace(s)
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(dir, gitDir)
+		}
+	}
+	r := &Repo{WorkTree: dir, GitDir: gitDir, CommonDir: gitDir}
+	if b, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(b))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		r.CommonDir = filepath.Clean(common)
+	}
+	if err := r.checkFormat(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// checkFormat rejects repositories using an object format other than SHA-1.
+func (r *Repo) checkFormat() error {
+	f, err := os.Open(filepath.Join(r.CommonDir, "config"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), "=")
+		if ok && strings.EqualFold(strings.TrimSpace(k), "objectformat") && strings.TrimSpace(v) != "sha1" {
+			return fmt.Errorf("%s: unsupported object format %s", r.CommonDir, strings.TrimSpace(v))
+		}
+	}
+	return nil
+}
+
+// Head returns the commit HEAD points to. It returns the zero ID without
+// an error on an unborn branch.
+func (r *Repo) Head() (ID, error) {
+	return r.resolve("HEAD", 0)
+}
+
+func (r *Repo) resolve(ref string, depth int) (ID, error) {
+	if depth > 10 {
+		return ID{}, fmt.Errorf("%s: symbolic ref loop", ref)
+	}
+	dir := r.CommonDir
+	if ref == "HEAD" {
+		dir = r.GitDir
+	}
+	b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref)))
+	if err == nil {
+		s := strings.TrimSpace(string(b))
+		if target, ok := strings.CutPrefix(s, "ref:"); ok {
+			return r.resolve(strings.TrimSpace(target), depth+1)
+		}
+		return ParseID(s)
+	}
+	if !os.IsNotExist(err) {
+		return ID{}, err
+	}
+	id, ok, err := r.packedRef(ref)
+	if err != nil || ok {
+		return id, err
+	}
+	return ID{}, nil // unborn branch
+}
+
+// packedRef looks ref up in the packed-refs file.
+func (r *Repo) packedRef(ref string) (ID, bool, error) {
+	b, err := os.ReadFile(filepath.Join(r.CommonDir, "packed-refs"))
+	if os.IsNotExist(err) {
+		return ID{}, false, nil
+	}
+	if err != nil {
+		return ID{}, false, err
+	}
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if len(line) == 0 || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		hexID, name, ok := bytes.Cut(line, []byte(" "))
+		if ok && string(bytes.TrimSpace(name)) == ref {
+			id, err := ParseID(string(hexID))
+			return id, err == nil, err
+		}
+	}
+	return ID{}, false, nil
+}
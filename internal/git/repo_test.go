@@ -0,0 +1,115 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testRepo is a scratch repository built with the git binary, against
+// which the native reader is compared.
+type testRepo struct {
+	t    *testing.T
+	dir  string
+	tick int
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	tr := &testRepo{t: t, dir: t.TempDir()}
+	tr.git("init", "-q", "-b", "main")
+	return tr
+}
+
+// git runs a git command in the repository and returns its trimmed
+// output. Commits get increasing, fixed timestamps so history order does
+// not depend on how fast the test runs.
+func (tr *testRepo) git(args ...string) string {
+	tr.t.Helper()
+	tr.tick++
+	date := strconv.Itoa(1700000000+tr.tick*60) + " +0000"
+	cmd := exec.Command("git", args...)
+	cmd.Dir = tr.dir
+	cmd.Env = append(os.Environ(),
+		"HOME="+tr.dir,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com", "GIT_COMMITTER_DATE="+date,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		tr.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (tr *testRepo) write(name, content string) {
+	tr.t.Helper()
+	path := filepath.Join(tr.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		tr.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		tr.t.Fatal(err)
+	}
+}
+
+func (tr *testRepo) commit(msg string) ID {
+	tr.t.Helper()
+	tr.git("add", "-A")
+	tr.git("commit", "-q", "-m", msg)
+	return tr.id("HEAD")
+}
+
+func (tr *testRepo) id(rev string) ID {
+	tr.t.Helper()
+	id, err := ParseID(tr.git("rev-parse", rev))
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+	return id
+}
+
+func (tr *testRepo) open() *Repo {
+	tr.t.Helper()
+	r, err := Discover(tr.dir)
+	if err != nil {
+		tr.t.Fatal(err)
+	}
+	tr.t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestDiscoverAndHead(t *testing.T) {
+	tr := newTestRepo(t)
+	r := tr.open()
+	if head, err := r.Head(); err != nil || !head.IsZero() {
+		t.Fatalf("Head() on an unborn branch = %v, %v; want zero ID", head, err)
+	}
+
+	tr.write("a/b/c.txt", "c\n")
+	want := tr.commit("first")
+	r, err := Discover(filepath.Join(tr.dir, "a", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head, err := r.Head(); err != nil || head != want {
+		t.Errorf("Head() = %v, %v; want %v", head, err, want)
+	}
+
+	// After pack-refs the branch is only in packed-refs.
+	tr.git("pack-refs", "--all")
+	if head, err := tr.open().Head(); err != nil || head != want {
+		t.Errorf("Head() from packed-refs = %v, %v; want %v", head, err, want)
+	}
+
+	if _, err := Discover(t.TempDir()); err != ErrNotRepository {
+		t.Errorf("Discover outside a repository: err = %v, want ErrNotRepository", err)
+	}
+}
@@ -0,0 +1,95 @@
+package out
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// EnvelopeVersion is the version of the --envelope result shape. It only
+// changes when the envelope itself changes incompatibly.
+const EnvelopeVersion = 1
+
+type envelope struct {
+	SchemaVersion int          `json:"schema_version"`
+	Command       string       `json:"command"`
+	Args          []string     `json:"args"`
+	Host          string       `json:"host"`
+	GeneratedAt   string       `json:"generated_at"` // RFC3339 UTC, when the command started
+	DurationMs    int64        `json:"duration_ms"`
+	Items         []any        `json:"items"`
+	Errors        []*ItemError `json:"errors"`
+}
+
+// envelopeEncoder collects records and writes them, together with run
+// metadata and per-item errors, as a single document on close.
+type envelopeEncoder struct {
+	w      *bufio.Writer
+	format string
+	start  time.Time
+	env    envelope
+}
+
+func newEnvelopeEncoder(format string, w *bufio.Writer, opts Options) (*envelopeEncoder, error) {
+	switch format {
+	case "", "json", "compact", "yaml":
+	default:
+		return nil, fmt.Errorf("--envelope is not supported with --format %s", format)
+	}
+	start := time.Now()
+	host, _ := os.Hostname()
+	args := opts.Args
+	if args == nil {
+		args = []string{}
+	}
+	return &envelopeEncoder{
+		w:      w,
+		format: format,
+		start:  start,
+		env: envelope{
+			SchemaVersion: EnvelopeVersion,
+			Command:       opts.Command,
+			Args:          args,
+			Host:          host,
+			GeneratedAt:   start.UTC().Format(time.RFC3339),
+			Items:         make([]any, 0),
+			Errors:        make([]*ItemError, 0),
+		},
+	}, nil
+}
+
+func (e *envelopeEncoder) encode(rec any) error {
+	e.env.Items = append(e.env.Items, rec)
+	return nil
+}
+
+func (e *envelopeEncoder) close() error {
+	e.env.DurationMs = time.Since(e.start).Milliseconds()
+	switch e.format {
+	case "compact":
+		b, err := json.Marshal(e.env)
+		if err != nil {
+			return err
+		}
+		e.w.Write(b)
+	case "yaml":
+		v, err := toValue(e.env)
+		if err != nil {
+			return err
+		}
+		o := v.(*object)
+		for _, k := range o.keys {
+			writeYAMLEntry(e.w, k, o.values[k], 0)
+		}
+		return nil
+	default:
+		b, err := json.MarshalIndent(e.env, "", "  ")
+		if err != nil {
+			return err
+		}
+		e.w.Write(b)
+	}
+	return e.w.WriteByte('\n')
+}
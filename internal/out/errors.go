@@ -0,0 +1,87 @@
+package out
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// ItemError describes a failure to collect one item (a path or a process)
+// while the command as a whole carried on.
+type ItemError struct {
+	Path    string `json:"path,omitempty"`
+	PID     int    `json:"pid,omitempty"`
+	Op      string `json:"op"`              // failed operation, e.g. "lstat", "open", "readdir"
+	Errno   string `json:"errno,omitempty"` // symbolic errno, e.g. "EACCES"
+	Message string `json:"error"`
+
+	err error
+}
+
+func (e *ItemError) Error() string {
+	return e.Message
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.err
+}
+
+// NewItemError describes err, taking the operation and path from an
+// *fs.PathError when there is one. op is used otherwise.
+func NewItemError(op string, err error) *ItemError {
+	e := &ItemError{Op: op, Errno: Errno(err), Message: err.Error(), err: err}
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		e.Op = pe.Op
+		e.Path = pe.Path
+	}
+	return e
+}
+
+var errnoNames = map[syscall.Errno]string{
+	syscall.EPERM:        "EPERM",
+	syscall.ENOENT:       "ENOENT",
+	syscall.ESRCH:        "ESRCH",
+	syscall.EINTR:        "EINTR",
+	syscall.EIO:          "EIO",
+	syscall.ENXIO:        "ENXIO",
+	syscall.EBADF:        "EBADF",
+	syscall.EAGAIN:       "EAGAIN",
+	syscall.ENOMEM:       "ENOMEM",
+	syscall.EACCES:       "EACCES",
+	syscall.EFAULT:       "EFAULT",
+	syscall.EBUSY:        "EBUSY",
+	syscall.EEXIST:       "EEXIST",
+	syscall.ENODEV:       "ENODEV",
+	syscall.ENOTDIR:      "ENOTDIR",
+	syscall.EISDIR:       "EISDIR",
+	syscall.EINVAL:       "EINVAL",
+	syscall.ENFILE:       "ENFILE",
+	syscall.EMFILE:       "EMFILE",
+	syscall.ENOSPC:       "ENOSPC",
+	syscall.EROFS:        "EROFS",
+	syscall.ENAMETOOLONG: "ENAMETOOLONG",
+	syscall.ENOSYS:       "ENOSYS",
+	syscall.ELOOP:        "ELOOP",
+	syscall.ENOTSUP:      "ENOTSUP",
+	syscall.ETIMEDOUT:    "ETIMEDOUT",
+	syscall.ESTALE:       "ESTALE",
+}
+
+// Errno returns the symbolic errno name behind err, or "" if there is none.
+// Permission and existence errors are named portably.
+func Errno(err error) string {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		if name, ok := errnoNames[errno]; ok {
+			return name
+		}
+	}
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return "EACCES"
+	case errors.Is(err, fs.ErrNotExist):
+		return "ENOENT"
+	}
+	return ""
+}
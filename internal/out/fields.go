@@ -0,0 +1,230 @@
+package out
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/antonmedv/jout/internal/expr"
+)
+
+// column is a leaf field of a record type, addressed by its dotted JSON path
+// (e.g. "io.read_bytes").
+type column struct {
+	name string
+	path []string
+}
+
+var (
+	jsonMarshaler = reflect.TypeFor[json.Marshaler]()
+	textMarshaler = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// columnsOf flattens the exported, JSON-visible fields of t into columns,
+// descending into nested structs (and pointers to them) so that e.g.
+// ps.Process.IO.ReadBytes becomes "io.read_bytes".
+func columnsOf(t reflect.Type) []column {
+	var cols []column
+	walkFields(t, nil, func(path []string, ft reflect.Type) bool {
+		if isStruct(ft) {
+			return true
+		}
+		cols = append(cols, column{name: strings.Join(path, "."), path: path})
+		return false
+	})
+	return cols
+}
+
+// fieldNames returns every dotted JSON path of t, including nested structs
+// themselves (e.g. "io" as well as "io.read_bytes").
+func fieldNames(t reflect.Type) []string {
+	var names []string
+	walkFields(t, nil, func(path []string, ft reflect.Type) bool {
+		names = append(names, strings.Join(path, "."))
+		return isStruct(ft)
+	})
+	return names
+}
+
+// fieldTypes maps every field name of t to its type in filter expressions.
+func fieldTypes(t reflect.Type) map[string]expr.Type {
+	types := make(map[string]expr.Type)
+	walkFields(t, nil, func(path []string, ft reflect.Type) bool {
+		types[strings.Join(path, ".")] = exprType(ft)
+		return isStruct(ft)
+	})
+	return types
+}
+
+func exprType(t reflect.Type) expr.Type {
+	if t.Implements(jsonMarshaler) || t.Implements(textMarshaler) {
+		return expr.Any
+	}
+	switch deref(t).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return expr.Number
+	case reflect.String:
+		return expr.String
+	case reflect.Bool:
+		return expr.Bool
+	}
+	return expr.Any
+}
+
+// projection selects a subset of fields from records.
+type projection struct {
+	paths [][]string
+
+	// nested names the fields holding records of the same type, such as
+	// ls.Entry.Children; their records are projected as well.
+	nested map[string]bool
+}
+
+// newProjection parses a comma-separated list of field paths and checks each
+// against the fields of t.
+func newProjection(list string, t reflect.Type) (*projection, error) {
+	valid := fieldNames(t)
+	p := &projection{}
+	for _, f := range strings.Split(list, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !slices.Contains(valid, f) {
+			return nil, fmt.Errorf("unknown field %q (valid fields: %s)", f, strings.Join(valid, ", "))
+		}
+		p.paths = append(p.paths, strings.Split(f, "."))
+	}
+	if len(p.paths) == 0 {
+		return nil, errors.New("no fields selected")
+	}
+	walkFields(t, nil, func(path []string, ft reflect.Type) bool {
+		if k := ft.Kind(); (k == reflect.Slice || k == reflect.Array) && deref(ft.Elem()) == deref(t) {
+			if p.nested == nil {
+				p.nested = make(map[string]bool)
+			}
+			p.nested[path[0]] = true
+		}
+		return false
+	})
+	return p, nil
+}
+
+// columns narrows cols to the selected fields, in selection order.
+func (p *projection) columns(cols []column) []column {
+	var sel []column
+	for _, path := range p.paths {
+		for _, c := range cols {
+			if hasPrefix(c.path, path) && !slices.ContainsFunc(sel, func(s column) bool { return s.name == c.name }) {
+				sel = append(sel, c)
+			}
+		}
+	}
+	return sel
+}
+
+// apply returns a new object holding only the selected fields of rec.
+// Fields absent from rec (omitted as empty) stay absent.
+func (p *projection) apply(rec any) (any, error) {
+	v, err := toValue(rec)
+	if err != nil {
+		return nil, err
+	}
+	return p.project(v), nil
+}
+
+// project selects fields from the generic record value v, recursing into
+// the records of nested fields.
+func (p *projection) project(v any) *object {
+	res := newObject()
+	for _, path := range p.paths {
+		fv, ok := lookup(v, path)
+		if !ok {
+			continue
+		}
+		if recs, isArr := fv.([]any); isArr && len(path) == 1 && p.nested[path[0]] {
+			projected := make([]any, len(recs))
+			for i, r := range recs {
+				projected[i] = p.project(r)
+			}
+			fv = projected
+		}
+		dst := res
+		for _, k := range path[:len(path)-1] {
+			next, ok := dst.get(k)
+			o, isObj := next.(*object)
+			if !ok || !isObj {
+				o = newObject()
+				dst.set(k, o)
+			}
+			dst = o
+		}
+		dst.set(path[len(path)-1], fv)
+	}
+	return res
+}
+
+func hasPrefix(path, prefix []string) bool {
+	return len(path) >= len(prefix) && slices.Equal(path[:len(prefix)], prefix)
+}
+
+// walkFields calls fn for every JSON field of struct type t in declaration
+// order. fn returns whether to descend into the field's own fields.
+func walkFields(t reflect.Type, prefix []string, fn func(path []string, ft reflect.Type) bool) {
+	t = deref(t)
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("json") == "" && deref(f.Type).Kind() == reflect.Struct {
+			// Fields of an embedded struct are encoded as if declared here.
+			walkFields(f.Type, prefix, fn)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		path := append(append([]string(nil), prefix...), name)
+		if fn(path, f.Type) {
+			walkFields(f.Type, path, fn)
+		}
+	}
+}
+
+func jsonName(f reflect.StructField) string {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	return f.Name
+}
+
+func deref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// isStruct reports whether values of t encode as a JSON object of fields.
+func isStruct(t reflect.Type) bool {
+	if t.Implements(jsonMarshaler) || t.Implements(textMarshaler) ||
+		reflect.PointerTo(deref(t)).Implements(jsonMarshaler) {
+		return false
+	}
+	return deref(t).Kind() == reflect.Struct
+}
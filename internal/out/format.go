@@ -0,0 +1,255 @@
+package out
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// Supported values of the --format flag.
+var formats = []string{"json", "compact", "ndjson", "yaml", "csv", "tsv", "table"}
+
+// encoder writes a sequence of records in one output format. Records are
+// either the original Go values or their generic form from toValue.
+type encoder interface {
+	encode(rec any) error
+	close() error
+}
+
+func newEncoder(format string, w *bufio.Writer, cols []column) (encoder, error) {
+	switch format {
+	case "", "json":
+		return &jsonEncoder{w: w, items: make([]any, 0)}, nil
+	case "compact":
+		return &compactEncoder{w: w}, nil
+	case "ndjson":
+		return &ndjsonEncoder{w: w, enc: json.NewEncoder(w)}, nil
+	case "yaml":
+		return &yamlEncoder{w: w}, nil
+	case "csv", "tsv":
+		cw := csv.NewWriter(w)
+		if format == "tsv" {
+			cw.Comma = '\t'
+		}
+		return &csvEncoder{w: w, cw: cw, cols: cols}, nil
+	case "table":
+		return &tableEncoder{tw: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0), cols: cols}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (want one of: %s)", format, strings.Join(formats, ", "))
+}
+
+// jsonEncoder collects records and prints them as one indented array.
+type jsonEncoder struct {
+	w     *bufio.Writer
+	items []any
+}
+
+func (e *jsonEncoder) encode(rec any) error {
+	e.items = append(e.items, rec)
+	return nil
+}
+
+func (e *jsonEncoder) close() error {
+	b, err := json.MarshalIndent(e.items, "", "  ")
+	if err != nil {
+		return err
+	}
+	e.w.Write(b)
+	return e.w.WriteByte('\n')
+}
+
+// compactEncoder streams records as a single-line JSON array.
+type compactEncoder struct {
+	w *bufio.Writer
+	n int
+}
+
+func (e *compactEncoder) encode(rec any) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if e.n == 0 {
+		e.w.WriteByte('[')
+	} else {
+		e.w.WriteByte(',')
+	}
+	e.n++
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *compactEncoder) close() error {
+	if e.n == 0 {
+		e.w.WriteByte('[')
+	}
+	_, err := e.w.WriteString("]\n")
+	return err
+}
+
+// ndjsonEncoder writes one JSON object per line and flushes after each.
+type ndjsonEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) encode(rec any) error {
+	if err := e.enc.Encode(rec); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+func (e *ndjsonEncoder) close() error { return nil }
+
+// yamlEncoder streams records as items of a YAML sequence.
+type yamlEncoder struct {
+	w *bufio.Writer
+	n int
+}
+
+func (e *yamlEncoder) encode(rec any) error {
+	v, err := toValue(rec)
+	if err != nil {
+		return err
+	}
+	e.n++
+	writeYAMLItem(e.w, v, 0)
+	return e.w.Flush()
+}
+
+func (e *yamlEncoder) close() error {
+	if e.n == 0 {
+		_, err := e.w.WriteString("[]\n")
+		return err
+	}
+	return nil
+}
+
+// csvEncoder writes a header of flattened column names followed by one row
+// per record. It also serves TSV with a tab separator.
+type csvEncoder struct {
+	w      *bufio.Writer
+	cw     *csv.Writer
+	cols   []column
+	header bool
+}
+
+func (e *csvEncoder) encode(rec any) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	row, err := cells(rec, e.cols)
+	if err != nil {
+		return err
+	}
+	if err := e.cw.Write(row); err != nil {
+		return err
+	}
+	e.cw.Flush()
+	if err := e.cw.Error(); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	names := make([]string, len(e.cols))
+	for i, c := range e.cols {
+		names[i] = c.name
+	}
+	return e.cw.Write(names)
+}
+
+func (e *csvEncoder) close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.cw.Flush()
+	return e.cw.Error()
+}
+
+// tableEncoder prints an aligned, human-readable table.
+type tableEncoder struct {
+	tw     *tabwriter.Writer
+	cols   []column
+	header bool
+}
+
+func (e *tableEncoder) encode(rec any) error {
+	e.writeHeader()
+	row, err := cells(rec, e.cols)
+	if err != nil {
+		return err
+	}
+	for i, c := range row {
+		row[i] = strings.Map(func(r rune) rune {
+			if r == '\t' || r == '\n' || r == '\r' {
+				return ' '
+			}
+			return r
+		}, c)
+	}
+	_, err = fmt.Fprintln(e.tw, strings.Join(row, "\t"))
+	return err
+}
+
+func (e *tableEncoder) writeHeader() {
+	if e.header {
+		return
+	}
+	e.header = true
+	names := make([]string, len(e.cols))
+	for i, c := range e.cols {
+		names[i] = strings.ToUpper(c.name)
+	}
+	fmt.Fprintln(e.tw, strings.Join(names, "\t"))
+}
+
+func (e *tableEncoder) close() error {
+	e.writeHeader()
+	return e.tw.Flush()
+}
+
+// cells renders rec as one text cell per column. Missing and null values
+// are empty; arrays and objects are embedded as compact JSON.
+func cells(rec any, cols []column) ([]string, error) {
+	v, err := toValue(rec)
+	if err != nil {
+		return nil, err
+	}
+	row := make([]string, len(cols))
+	for i, c := range cols {
+		cv, _ := lookup(v, c.path)
+		row[i], err = cellText(cv)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+func cellText(v any) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	case json.Number:
+		return t.String(), nil
+	case bool:
+		if t {
+			return "true", nil
+		}
+		return "false", nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
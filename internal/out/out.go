@@ -1,8 +1,16 @@
 package out
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/antonmedv/jout/internal/expr"
 )
 
 func JSON(v any) {
@@ -12,3 +20,180 @@ func JSON(v any) {
 	}
 	fmt.Println(string(b))
 }
+
+// Options are the output flags shared by every record-producing command.
+type Options struct {
+	Format string
+	Fields string
+	Where  string
+	Sort   string
+	Limit  int
+	Offset int
+
+	// Envelope wraps the output in a versioned object with run metadata
+	// and per-item errors. Command and Args describe the invocation in it.
+	Envelope bool
+	Command  string
+	Args     []string
+}
+
+// AddFlags defines the shared output flags on fs.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	o.Command = fs.Name()
+	fs.StringVar(&o.Format, "format", "json", "Output format: "+strings.Join(formats, ", ")+".")
+	fs.BoolFunc("ndjson", "Stream records as newline-delimited JSON (JSON Lines); same as --format ndjson.", func(string) error {
+		o.Format = "ndjson"
+		return nil
+	})
+	fs.StringVar(&o.Where, "where", "", `Keep only records matching an expression, e.g. 'mem_rss_bytes > 500e6 && comm =~ "^java"'.`)
+	fs.StringVar(&o.Sort, "sort", "", "Comma-separated fields to sort by; prefix with - for descending, e.g. -mem_rss_bytes,pid.")
+	fs.IntVar(&o.Limit, "limit", 0, "Emit at most this many records (0 means no limit).")
+	fs.IntVar(&o.Offset, "offset", 0, "Skip this many records before emitting.")
+	fs.StringVar(&o.Fields, "fields", "", "Comma-separated JSON field paths to keep, e.g. pid,comm,io.read_bytes.")
+	fs.BoolVar(&o.Envelope, "envelope", false, "Wrap output in {schema_version, command, args, host, generated_at, duration_ms, items, errors}.")
+}
+
+// Writer emits records of type T to stdout in the selected format. Columnar
+// formats (csv, tsv, table) take their columns from the JSON tags of T, with
+// nested structs flattened into dotted names.
+type Writer[T any] struct {
+	opts Options
+	buf  *bufio.Writer
+	enc  encoder
+	cond *expr.Expr
+	keys []sortKey
+	proj *projection
+
+	sorted  []any // records held back until Close when sorting
+	skipped int
+	written int
+	errors  []*ItemError
+}
+
+func NewWriter[T any](opts Options) (*Writer[T], error) {
+	t := reflect.TypeFor[T]()
+	cols := columnsOf(t)
+
+	var cond *expr.Expr
+	if opts.Where != "" {
+		var err error
+		if cond, err = expr.Parse(opts.Where, fieldTypes(t)); err != nil {
+			return nil, err
+		}
+	}
+
+	var keys []sortKey
+	if opts.Sort != "" {
+		var err error
+		if keys, err = parseSortKeys(opts.Sort, t); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Limit < 0 || opts.Offset < 0 {
+		return nil, errors.New("--limit and --offset must not be negative")
+	}
+
+	var proj *projection
+	if opts.Fields != "" {
+		var err error
+		if proj, err = newProjection(opts.Fields, t); err != nil {
+			return nil, err
+		}
+		cols = proj.columns(cols)
+	}
+
+	buf := bufio.NewWriter(os.Stdout)
+	var enc encoder
+	var err error
+	if opts.Envelope {
+		enc, err = newEnvelopeEncoder(opts.Format, buf, opts)
+	} else {
+		enc, err = newEncoder(opts.Format, buf, cols)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Writer[T]{opts: opts, buf: buf, enc: enc, cond: cond, keys: keys, proj: proj}, nil
+}
+
+// Streaming reports whether records are emitted as they are written, in
+// which case producers should not hold them back (e.g. to sort them).
+func (w *Writer[T]) Streaming() bool {
+	return w.opts.Format == "ndjson" && w.keys == nil
+}
+
+// Write passes v through the --where filter and then either holds it for
+// sorting or emits it, subject to --offset and --limit.
+func (w *Writer[T]) Write(v T) error {
+	var rec any = v
+	if w.cond != nil {
+		val, err := toValue(rec)
+		if err != nil {
+			return err
+		}
+		match := w.cond.Match(func(field string) any {
+			fv, _ := lookup(val, strings.Split(field, "."))
+			return fv
+		})
+		if !match {
+			return nil
+		}
+		rec = val
+	}
+	if w.keys != nil {
+		val, err := toValue(rec)
+		if err != nil {
+			return err
+		}
+		w.sorted = append(w.sorted, val)
+		return nil
+	}
+	return w.emit(rec)
+}
+
+func (w *Writer[T]) emit(rec any) error {
+	if w.skipped < w.opts.Offset {
+		w.skipped++
+		return nil
+	}
+	if w.opts.Limit > 0 && w.written >= w.opts.Limit {
+		return nil
+	}
+	w.written++
+	if w.proj != nil {
+		var err error
+		if rec, err = w.proj.apply(rec); err != nil {
+			return err
+		}
+	}
+	return w.enc.encode(rec)
+}
+
+// Error records a failure to collect one item. Errors are reported in the
+// envelope; without --envelope they are only counted.
+func (w *Writer[T]) Error(e *ItemError) {
+	w.errors = append(w.errors, e)
+}
+
+// Errors returns the number of item errors recorded so far.
+func (w *Writer[T]) Errors() int {
+	return len(w.errors)
+}
+
+func (w *Writer[T]) Close() error {
+	if w.keys != nil {
+		sortRecords(w.sorted, w.keys)
+		for _, rec := range w.sorted {
+			if err := w.emit(rec); err != nil {
+				return err
+			}
+		}
+	}
+	if env, ok := w.enc.(*envelopeEncoder); ok {
+		env.env.Errors = append(env.env.Errors, w.errors...)
+	}
+	if err := w.enc.close(); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
@@ -0,0 +1,128 @@
+package out
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sortKey is one field of a --sort specification.
+type sortKey struct {
+	path []string
+	desc bool
+}
+
+// parseSortKeys parses a comma-separated list of field paths, each
+// optionally prefixed with "-" for descending order, e.g. "-mem_rss_bytes,pid".
+func parseSortKeys(spec string, t reflect.Type) ([]sortKey, error) {
+	valid := columnsOf(t)
+	var keys []sortKey
+	for _, f := range strings.Split(spec, ",") {
+		f = strings.TrimSpace(f)
+		desc := strings.HasPrefix(f, "-")
+		f = strings.TrimPrefix(strings.TrimPrefix(f, "-"), "+")
+		if f == "" {
+			continue
+		}
+		if !slices.ContainsFunc(valid, func(c column) bool { return c.name == f }) {
+			names := make([]string, len(valid))
+			for i, c := range valid {
+				names[i] = c.name
+			}
+			return nil, fmt.Errorf("unknown sort field %q (valid fields: %s)", f, strings.Join(names, ", "))
+		}
+		keys = append(keys, sortKey{path: strings.Split(f, "."), desc: desc})
+	}
+	return keys, nil
+}
+
+// sortRecords stably sorts generic record values by keys. Missing and null
+// values sort last regardless of direction.
+func sortRecords(recs []any, keys []sortKey) {
+	slices.SortStableFunc(recs, func(a, b any) int {
+		for _, k := range keys {
+			av, _ := lookup(a, k.path)
+			bv, _ := lookup(b, k.path)
+			switch {
+			case av == nil && bv == nil:
+				continue
+			case av == nil:
+				return 1
+			case bv == nil:
+				return -1
+			}
+			c := compareValues(av, bv)
+			if k.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+}
+
+// compareValues orders two non-null JSON values: numbers numerically,
+// RFC 3339 timestamps chronologically, other strings lexically and
+// booleans false before true. Mixed kinds fall back to comparing text.
+func compareValues(a, b any) int {
+	switch av := a.(type) {
+	case json.Number:
+		if bv, ok := b.(json.Number); ok {
+			return compareNumbers(av, bv)
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			at, aerr := time.Parse(time.RFC3339Nano, av)
+			bt, berr := time.Parse(time.RFC3339Nano, bv)
+			if aerr == nil && berr == nil {
+				return at.Compare(bt)
+			}
+			return strings.Compare(av, bv)
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0
+			case !av:
+				return -1
+			}
+			return 1
+		}
+	}
+	as, _ := cellText(a)
+	bs, _ := cellText(b)
+	return strings.Compare(as, bs)
+}
+
+// compareNumbers compares integers exactly and everything else as floats.
+// Unsigned values above math.MaxInt64, such as inode numbers and device
+// ids, are compared as uint64 so they keep their full precision.
+func compareNumbers(a, b json.Number) int {
+	ai, aerr := strconv.ParseInt(string(a), 10, 64)
+	bi, berr := strconv.ParseInt(string(b), 10, 64)
+	if aerr == nil && berr == nil {
+		return cmp.Compare(ai, bi)
+	}
+	au, auerr := strconv.ParseUint(string(a), 10, 64)
+	bu, buerr := strconv.ParseUint(string(b), 10, 64)
+	switch {
+	case auerr == nil && buerr == nil:
+		return cmp.Compare(au, bu)
+	case aerr == nil && buerr == nil:
+		// b is above math.MaxInt64, so it is larger than any int64.
+		return -1
+	case auerr == nil && berr == nil:
+		return 1
+	}
+	af, _ := strconv.ParseFloat(string(a), 64)
+	bf, _ := strconv.ParseFloat(string(b), 64)
+	return cmp.Compare(af, bf)
+}
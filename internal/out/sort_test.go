@@ -0,0 +1,207 @@
+package out
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type sortRec struct {
+	Name  string  `json:"name"`
+	Inode uint64  `json:"inode"`
+	Size  *int64  `json:"size,omitempty"`
+	Mtime string  `json:"mtime,omitempty"`
+	Exec  bool    `json:"exec"`
+	Score float64 `json:"score"`
+}
+
+func size(n int64) *int64 { return &n }
+
+var sortRecs = []sortRec{
+	{Name: "b", Inode: 18446744073709551615, Size: size(10), Mtime: "2024-01-01T09:00:00Z", Exec: true, Score: 1.5},
+	{Name: "a", Inode: 18446744073709551614, Mtime: "2024-01-01T10:00:00.5+02:00", Score: 2},
+	{Name: "c", Inode: 9223372036854775807, Size: size(-3), Score: -1},
+	{Name: "d", Inode: 1, Size: size(10), Mtime: "2024-01-01T09:00:00.000000001Z", Exec: true, Score: 1.25},
+}
+
+// sortNames sorts sortRecs by spec and returns the names in order.
+func sortNames(t *testing.T, spec string) string {
+	t.Helper()
+	keys, err := parseSortKeys(spec, reflect.TypeFor[sortRec]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs := make([]any, len(sortRecs))
+	for i, r := range sortRecs {
+		if recs[i], err = toValue(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sortRecords(recs, keys)
+	var names []string
+	for _, r := range recs {
+		v, _ := lookup(r, []string{"name"})
+		names = append(names, v.(string))
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSortRecords(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"name", "a,b,c,d"},
+		{"-name", "d,c,b,a"},
+		{"+name", "a,b,c,d"},
+
+		// uint64 values above MaxInt64 keep their full precision.
+		{"inode", "d,c,a,b"},
+		{"-inode", "b,a,c,d"},
+
+		{"score", "c,d,b,a"},
+
+		// Records without the key sort last in both directions; ties keep
+		// their input order.
+		{"size", "c,b,d,a"},
+		{"-size", "b,d,c,a"},
+		{"size,-name", "c,d,b,a"},
+
+		// Timestamps compare chronologically, not as text: 10:00+02:00 is
+		// the earliest.
+		{"mtime", "a,b,d,c"},
+		{"-mtime", "d,b,a,c"},
+
+		{"exec,name", "a,c,b,d"},
+		{"-exec,-name", "d,b,c,a"},
+	}
+	for _, tt := range tests {
+		if got := sortNames(t, tt.spec); got != tt.want {
+			t.Errorf("--sort %s: %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseSortKeysUnknownField(t *testing.T) {
+	_, err := parseSortKeys("name,nope", reflect.TypeFor[sortRec]())
+	if err == nil || !strings.Contains(err.Error(), `unknown sort field "nope"`) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestCompareNumbers(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1", "2", -1},
+		{"-5", "-5", 0},
+		{"18446744073709551615", "18446744073709551614", 1},
+		{"9223372036854775808", "9223372036854775807", 1},
+		{"-1", "18446744073709551615", -1},
+		{"18446744073709551615", "-1", 1},
+		{"1.5", "2", -1},
+		{"2.5e3", "2500", 0},
+		// Beyond 2^53 integers compare exactly, unlike their float64 values.
+		{"9007199254740993", "9007199254740992", 1},
+	}
+	for _, tt := range tests {
+		if got := compareNumbers(json.Number(tt.a), json.Number(tt.b)); got != tt.want {
+			t.Errorf("compareNumbers(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCompareValuesMixedKinds(t *testing.T) {
+	tests := []struct {
+		a, b any
+		want int
+	}{
+		{json.Number("10"), "9", -1}, // mixed kinds compare as text
+		{"abc", "abd", -1},
+		{false, true, -1},
+		{true, true, 0},
+		{true, json.Number("1"), 1},
+	}
+	for _, tt := range tests {
+		if got := compareValues(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareValues(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// writeAll writes sortRecs through a Writer with opts and returns the
+// names it emitted.
+func writeAll(t *testing.T, opts Options) string {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stdout := os.Stdout
+	os.Stdout = f
+	defer func() { os.Stdout = stdout }()
+
+	opts.Format = "ndjson"
+	w, err := NewWriter[sortRec](opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range sortRecs {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Seek(0, io.SeekStart)
+	dec := json.NewDecoder(f)
+	var names []string
+	for {
+		var r sortRec
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, r.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestWriterSortLimitOffset(t *testing.T) {
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		{Options{}, "b,a,c,d"},
+		{Options{Limit: 2}, "b,a"},
+		{Options{Offset: 3}, "d"},
+		{Options{Offset: 4}, ""},
+		{Options{Offset: 10, Limit: 1}, ""},
+		{Options{Sort: "name", Offset: 1, Limit: 2}, "b,c"},
+		{Options{Sort: "-inode", Limit: 1}, "b"},
+		{Options{Sort: "size", Offset: 3}, "a"},
+		{Options{Sort: "name", Offset: 5}, ""},
+		{Options{Sort: "name", Where: "exec", Offset: 1}, "d"},
+	}
+	for _, tt := range tests {
+		if got := writeAll(t, tt.opts); got != tt.want {
+			t.Errorf("%+v: %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestWriterRejectsNegativeLimit(t *testing.T) {
+	for _, opts := range []Options{{Limit: -1}, {Offset: -1}} {
+		if _, err := NewWriter[sortRec](opts); err == nil {
+			t.Errorf("%+v: no error", opts)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package out
+
+import (
+	"bufio"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// writeYAMLItem writes v as one item of a block sequence at the given indent.
+func writeYAMLItem(w *bufio.Writer, v any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	w.WriteString(pad)
+	w.WriteString("-")
+	switch t := v.(type) {
+	case *object:
+		if len(t.keys) == 0 {
+			w.WriteString(" {}\n")
+			return
+		}
+		// The first key shares the line with the dash, the rest align with it.
+		for i, k := range t.keys {
+			if i == 0 {
+				w.WriteString(" ")
+			} else {
+				w.WriteString(pad + "  ")
+			}
+			writeYAMLEntry(w, k, t.values[k], indent+1)
+		}
+	case []any:
+		if len(t) == 0 {
+			w.WriteString(" []\n")
+			return
+		}
+		w.WriteString("\n")
+		for _, e := range t {
+			writeYAMLItem(w, e, indent+1)
+		}
+	default:
+		w.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+// writeYAMLEntry writes "key: value" where nested values are indented at
+// the given level. The caller has already written the key's indentation.
+func writeYAMLEntry(w *bufio.Writer, key string, v any, indent int) {
+	w.WriteString(yamlScalar(key))
+	w.WriteString(":")
+	pad := strings.Repeat("  ", indent+1)
+	switch t := v.(type) {
+	case *object:
+		if len(t.keys) == 0 {
+			w.WriteString(" {}\n")
+			return
+		}
+		w.WriteString("\n")
+		for _, k := range t.keys {
+			w.WriteString(pad)
+			writeYAMLEntry(w, k, t.values[k], indent+1)
+		}
+	case []any:
+		if len(t) == 0 {
+			w.WriteString(" []\n")
+			return
+		}
+		w.WriteString("\n")
+		for _, e := range t {
+			writeYAMLItem(w, e, indent+1)
+		}
+	default:
+		w.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+func yamlScalar(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(t)
+	case json.Number:
+		return t.String()
+	case string:
+		if yamlPlain(t) {
+			return t
+		}
+		// JSON string escapes are valid in YAML double-quoted scalars.
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// yamlPlain reports whether s can be written unquoted without being read
+// back as anything other than the same string.
+func yamlPlain(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off", "y", "n", ".inf", "-.inf", ".nan":
+		return false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return false
+	}
+	// Leading digits may read as numbers, dates or times in some parsers.
+	if strings.ContainsAny(s[:1], "0123456789+.-?:,[]{}#&*!|>'\"%@`") {
+		return false
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	return !strings.Contains(s, ": ") && !strings.Contains(s, " #") && !strings.HasSuffix(s, ":")
+}
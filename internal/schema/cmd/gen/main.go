@@ -0,0 +1,62 @@
+// Command gen writes the JSON Schema of every command's output from the Go
+// types behind it. Run it through `go generate ./internal/schema`.
+//
+// With -check it regenerates in memory instead and exits non-zero when the
+// committed schemas are stale, reporting changes that would break consumers
+// of the committed schema (removed fields, changed types, fields that are no
+// longer always present).
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/antonmedv/jout/internal/schema/gen"
+)
+
+func main() {
+	check := flag.Bool("check", false, "verify the committed schemas instead of writing them")
+	flag.Parse()
+
+	failed := false
+	for _, t := range gen.Targets {
+		b, err := gen.Generate(t.Command, t.Dir, t.Type)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", t.Command, err)
+			os.Exit(1)
+		}
+		file := t.Command + ".schema.json"
+		if !*check {
+			if err := os.WriteFile(file, b, 0o644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			continue
+		}
+
+		old, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		if bytes.Equal(old, b) {
+			continue
+		}
+		failed = true
+		problems, err := gen.Breaking(old, b)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			continue
+		}
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "%s: breaking change: %s\n", file, p)
+		}
+		fmt.Fprintf(os.Stderr, "%s: out of date; run go generate ./internal/schema\n", file)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
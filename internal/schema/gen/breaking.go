@@ -0,0 +1,101 @@
+package gen
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Breaking lists the differences between the old and new schema documents
+// that could break a consumer written against old. Additions (new types,
+// fields, enum values) are not reported.
+func Breaking(oldDoc, newDoc []byte) ([]string, error) {
+	var oldS, newS map[string]any
+	if err := json.Unmarshal(oldDoc, &oldS); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(newDoc, &newS); err != nil {
+		return nil, err
+	}
+	oldDefs, _ := oldS["$defs"].(map[string]any)
+	newDefs, _ := newS["$defs"].(map[string]any)
+
+	var problems []string
+	for _, name := range sortedKeys(oldDefs) {
+		od, _ := oldDefs[name].(map[string]any)
+		nd, ok := newDefs[name].(map[string]any)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("type %s removed", name))
+			continue
+		}
+		oldProps, _ := od["properties"].(map[string]any)
+		newProps, _ := nd["properties"].(map[string]any)
+		oldReq := stringList(od["required"])
+		newReq := stringList(nd["required"])
+		for _, prop := range sortedKeys(oldProps) {
+			np, ok := newProps[prop]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s removed", name, prop))
+				continue
+			}
+			ot, nt := typesOf(oldProps[prop]), typesOf(np)
+			for _, t := range nt {
+				if !slices.Contains(ot, t) {
+					problems = append(problems, fmt.Sprintf("%s.%s type changed from %s to %s",
+						name, prop, strings.Join(ot, "|"), strings.Join(nt, "|")))
+					break
+				}
+			}
+			if slices.Contains(oldReq, prop) && !slices.Contains(newReq, prop) {
+				problems = append(problems, fmt.Sprintf("%s.%s is no longer required", name, prop))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// typesOf returns the JSON types a property schema admits, naming
+// referenced definitions as "$ref:Name".
+func typesOf(v any) []string {
+	s, _ := v.(map[string]any)
+	var types []string
+	switch t := s["type"].(type) {
+	case string:
+		types = append(types, t)
+	case []any:
+		for _, e := range t {
+			types = append(types, fmt.Sprint(e))
+		}
+	}
+	if r, ok := s["$ref"].(string); ok {
+		types = append(types, "$ref:"+strings.TrimPrefix(r, "#/$defs/"))
+	}
+	if alts, ok := s["anyOf"].([]any); ok {
+		for _, a := range alts {
+			types = append(types, typesOf(a)...)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+func stringList(v any) []string {
+	var list []string
+	if arr, ok := v.([]any); ok {
+		for _, e := range arr {
+			list = append(list, fmt.Sprint(e))
+		}
+	}
+	return list
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,293 @@
+// Package gen generates the JSON Schema of a command's output from the Go
+// record types behind it, and compares generated schemas for changes that
+// would break consumers.
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Targets lists the record type of each command, relative to internal/schema.
+var Targets = []struct {
+	Command string
+	Dir     string
+	Type    string
+}{
+	{"ls", "../../pkg/ls", "Entry"},
+	{"ps", "../../pkg/ps", "Process"},
+	{"lsof", "../../pkg/ps", "OpenFile"},
+}
+
+// object is a JSON object that keeps keys in insertion order.
+type object struct {
+	keys []string
+	vals map[string]any
+}
+
+func newObject() *object { return &object{vals: map[string]any{}} }
+
+func (o *object) set(k string, v any) *object {
+	if _, ok := o.vals[k]; !ok {
+		o.keys = append(o.keys, k)
+	}
+	o.vals[k] = v
+	return o
+}
+
+func (o *object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		kb, _ := json.Marshal(k)
+		b.Write(kb)
+		b.WriteByte(':')
+		vb, err := json.Marshal(o.vals[k])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(vb)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+type generator struct {
+	types map[string]*ast.TypeSpec
+	docs  map[string]string
+	defs  *object
+}
+
+// Generate returns the schema document for command, whose records are of
+// type typ declared in the Go package in dir.
+func Generate(command, dir, typ string) ([]byte, error) {
+	g := &generator{types: map[string]*ast.TypeSpec{}, docs: map[string]string{}, defs: newObject()}
+	if err := g.load(dir); err != nil {
+		return nil, err
+	}
+	if _, ok := g.types[typ]; !ok {
+		return nil, fmt.Errorf("type %s not found in %s", typ, dir)
+	}
+	if err := g.define(typ); err != nil {
+		return nil, err
+	}
+
+	root := newObject().
+		set("$schema", "https://json-schema.org/draft/2020-12/schema").
+		set("title", "jout "+command).
+		set("description", "Output of `jout "+command+"`: an array of "+typ+" records.").
+		set("type", "array").
+		set("items", ref(typ)).
+		set("$defs", g.defs)
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// load collects the type declarations of every non-test Go file in dir.
+func (g *generator) load(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				g.types[ts.Name.Name] = ts
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				if doc != nil {
+					g.docs[ts.Name.Name] = strings.TrimSpace(doc.Text())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func ref(name string) *object {
+	return newObject().set("$ref", "#/$defs/"+name)
+}
+
+// enumRe matches a leading "a|b|c" list in a field comment.
+var enumRe = regexp.MustCompile(`^[A-Za-z0-9_]+(\|[A-Za-z0-9_]+)+\b`)
+
+// define adds the schema of struct type name to $defs, along with every
+// struct type it references.
+func (g *generator) define(name string) error {
+	if _, done := g.defs.vals[name]; done {
+		return nil
+	}
+	st, ok := g.types[name].Type.(*ast.StructType)
+	if !ok {
+		return fmt.Errorf("%s is not a struct", name)
+	}
+	def := newObject()
+	g.defs.set(name, def) // reserve the slot so recursive types terminate
+	if doc := g.docs[name]; doc != "" {
+		def.set("description", doc)
+	}
+	def.set("type", "object")
+
+	props := newObject()
+	var required []string
+	if err := g.fields(name, st, props, &required); err != nil {
+		return err
+	}
+	def.set("properties", props)
+	if len(required) > 0 {
+		def.set("required", required)
+	}
+	return nil
+}
+
+// fields adds the properties of the fields of st, declared in type name,
+// to props, and the names of those always present to required. The fields
+// of embedded structs are added as if declared in st, as encoding/json
+// does.
+func (g *generator) fields(name string, st *ast.StructType, props *object, required *[]string) error {
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			id, ok := f.Type.(*ast.Ident)
+			if !ok || g.types[id.Name] == nil || f.Tag != nil {
+				return fmt.Errorf("%s: unsupported embedded field", name)
+			}
+			embedded, ok := g.types[id.Name].Type.(*ast.StructType)
+			if !ok {
+				return fmt.Errorf("%s: embedded %s is not a struct", name, id.Name)
+			}
+			if err := g.fields(id.Name, embedded, props, required); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.Names[0].IsExported() {
+			continue
+		}
+		jsonName, omitEmpty := f.Names[0].Name, false
+		if f.Tag != nil {
+			tag, _ := strconv.Unquote(f.Tag.Value)
+			opts := strings.Split(reflect.StructTag(tag).Get("json"), ",")
+			if opts[0] == "-" {
+				continue
+			}
+			if opts[0] != "" {
+				jsonName = opts[0]
+			}
+			omitEmpty = slices.Contains(opts[1:], "omitempty")
+		}
+
+		s, err := g.schemaOf(f.Type, !omitEmpty)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", name, f.Names[0].Name, err)
+		}
+		if f.Comment != nil {
+			comment := strings.TrimSpace(f.Comment.Text())
+			if m := enumRe.FindString(comment); m != "" {
+				s.set("enum", strings.Split(m, "|"))
+			}
+			s.set("description", comment)
+		}
+		props.set(jsonName, s)
+		if !omitEmpty {
+			*required = append(*required, jsonName)
+		}
+	}
+	return nil
+}
+
+// schemaOf returns the schema of a Go type expression. nilable reports
+// whether a nil pointer, slice or map would be encoded as null rather than
+// omitted.
+func (g *generator) schemaOf(expr ast.Expr, nilable bool) (*object, error) {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		s, err := g.schemaOf(t.X, false)
+		if err != nil || !nilable {
+			return s, err
+		}
+		return orNull(s), nil
+	case *ast.ArrayType:
+		items, err := g.schemaOf(t.Elt, true)
+		if err != nil {
+			return nil, err
+		}
+		s := newObject().set("type", "array").set("items", items)
+		if nilable {
+			s = orNull(s)
+		}
+		return s, nil
+	case *ast.MapType:
+		vals, err := g.schemaOf(t.Value, true)
+		if err != nil {
+			return nil, err
+		}
+		s := newObject().set("type", "object").set("additionalProperties", vals)
+		if nilable {
+			s = orNull(s)
+		}
+		return s, nil
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return newObject().set("type", "string"), nil
+		case "bool":
+			return newObject().set("type", "boolean"), nil
+		case "int", "int8", "int16", "int32", "int64":
+			return newObject().set("type", "integer"), nil
+		case "uint", "uint8", "uint16", "uint32", "uint64":
+			return newObject().set("type", "integer").set("minimum", 0), nil
+		case "float32", "float64":
+			return newObject().set("type", "number"), nil
+		}
+		if _, ok := g.types[t.Name]; ok {
+			if err := g.define(t.Name); err != nil {
+				return nil, err
+			}
+			return ref(t.Name), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported type %T", expr)
+}
+
+// orNull widens s to also accept null.
+func orNull(s *object) *object {
+	if t, ok := s.vals["type"].(string); ok {
+		return s.set("type", []string{t, "null"})
+	}
+	return newObject().set("anyOf", []any{s, newObject().set("type", "null")})
+}
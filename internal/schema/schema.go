@@ -0,0 +1,35 @@
+// Package schema holds the JSON Schema (Draft 2020-12) of every command's
+// output. The files are generated from the Go record types; regenerate them
+// after changing a record type and verify compatibility with:
+//
+//	go generate ./internal/schema
+//	go test ./internal/schema
+package schema
+
+import (
+	"embed"
+	"sort"
+	"strings"
+)
+
+//go:generate go run ./cmd/gen
+
+//go:embed *.schema.json
+var files embed.FS
+
+// Get returns the schema document for the named command.
+func Get(command string) ([]byte, bool) {
+	b, err := files.ReadFile(command + ".schema.json")
+	return b, err == nil
+}
+
+// Commands lists the commands that have a schema.
+func Commands() []string {
+	entries, _ := files.ReadDir(".")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".schema.json"))
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,34 @@
+package schema
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/antonmedv/jout/internal/schema/gen"
+)
+
+func TestCommittedSchemasUpToDate(t *testing.T) {
+	for _, target := range gen.Targets {
+		t.Run(target.Command, func(t *testing.T) {
+			want, err := gen.Generate(target.Command, target.Dir, target.Type)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := Get(target.Command)
+			if !ok {
+				t.Fatalf("%s.schema.json is not committed", target.Command)
+			}
+			if bytes.Equal(got, want) {
+				return
+			}
+			problems, err := gen.Breaking(got, want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range problems {
+				t.Errorf("breaking change: %s", p)
+			}
+			t.Errorf("%s.schema.json is out of date; run go generate ./internal/schema", target.Command)
+		})
+	}
+}
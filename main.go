@@ -1,12 +1,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 
-	"github.com/antonmedv/jout/cmd/ls"
-	"github.com/antonmedv/jout/cmd/ps"
+	"github.com/antonmedv/jout/internal/cli"
+
+	_ "github.com/antonmedv/jout/cmd/ls"
+	_ "github.com/antonmedv/jout/cmd/lsof"
+	_ "github.com/antonmedv/jout/cmd/ps"
+	_ "github.com/antonmedv/jout/cmd/schema"
 )
 
 func main() {
@@ -14,42 +21,120 @@ func main() {
 }
 
 func run(args []string) int {
-	if len(args) < 2 {
-		usage()
+	// Errors as JSON on stderr: by default when stderr is not a terminal,
+	// overridden by JOUT_JSON_ERRORS and then by --json-errors[=bool]
+	// anywhere before a "--".
+	cli.JSONErrors = !isTerminal(os.Stderr)
+	if v, ok := os.LookupEnv("JOUT_JSON_ERRORS"); ok {
+		cli.JSONErrors, _ = strconv.ParseBool(v)
+	}
+	args, err := jsonErrorsFlag(args)
+	if err != nil {
+		cli.Report("", cli.CodeUsage, err)
 		return 2
 	}
 
-	var code int
-	var err error = nil
+	if len(args) < 2 {
+		cli.Usage(os.Stderr)
+		return 2
+	}
 
 	switch args[1] {
-	case "ls":
-		code, err = ls.Run(args[2:])
-	case "ps":
-		code, err = ps.Run(args[2:])
-	case "-h", "--help", "help":
-		usage()
+	case "-h", "--help":
+		cli.Usage(os.Stderr)
 		return 0
-	default:
-		fmt.Fprintf(os.Stderr, "unknown subcommand: %s\n", args[1])
-		usage()
+	case "help":
+		return help(args[2:])
+	}
+
+	cmd, ok := cli.Lookup(args[1])
+	if !ok {
+		msg := "unknown subcommand: " + args[1]
+		if s := cli.Suggest(args[1]); s != "" {
+			msg += " (did you mean " + s + "?)"
+		}
+		cli.Report("", cli.CodeUnknownCommand, errors.New(msg))
+		if !cli.JSONErrors {
+			cli.Usage(os.Stderr)
+		}
+		return 2
+	}
+	if !cmd.Supported() {
+		cli.Report(cmd.Name, cli.CodeUnsupportedPlatform, fmt.Errorf("jout %s is not supported on this platform", cmd.Name))
 		return 2
 	}
 
+	code, err := cmd.Run(args[2:])
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			code = exitErr.ExitCode()
-			fmt.Fprintf(os.Stderr, string(exitErr.Stderr))
-		} else {
-			fmt.Fprintf(os.Stderr, "%s\n", err)
+			if !cli.JSONErrors {
+				os.Stderr.Write(exitErr.Stderr)
+				return code
+			}
+			if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+				err = errors.New(msg)
+			}
 		}
+		errCode := cli.CodeRuntime
+		if code == 2 {
+			errCode = cli.CodeUsage
+		}
+		cli.Report(cmd.Name, errCode, err)
 	}
 	return code
 }
 
-func usage() {
-	fmt.Fprintln(os.Stderr, "jout — Run commands, get JSON.")
-	fmt.Fprintln(os.Stderr, "usage:")
-	fmt.Fprintln(os.Stderr, "  jout ls [-P|-H|-L] [path...]")
-	fmt.Fprintln(os.Stderr, "  jout ps [--user USER]")
+// jsonErrorsFlag sets cli.JSONErrors from --json-errors, --json-errors=BOOL
+// or --no-json-errors arguments, which may appear anywhere before "--", and
+// returns args without them.
+func jsonErrorsFlag(args []string) ([]string, error) {
+	rest := args[:1:1]
+	for i, a := range args[1:] {
+		if a == "--" {
+			return append(rest, args[i+1:]...), nil
+		}
+		name, val, hasVal := strings.Cut(strings.TrimPrefix(a, "-"), "=")
+		switch {
+		case name == "-json-errors" || name == "json-errors":
+			on := true
+			if hasVal {
+				b, err := strconv.ParseBool(val)
+				if err != nil {
+					return nil, fmt.Errorf("invalid boolean value %q for --json-errors", val)
+				}
+				on = b
+			}
+			cli.JSONErrors = on
+		case (name == "-no-json-errors" || name == "no-json-errors") && !hasVal:
+			cli.JSONErrors = false
+		default:
+			rest = append(rest, a)
+		}
+	}
+	return rest, nil
+}
+
+// isTerminal reports whether f is a terminal (a character device).
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func help(args []string) int {
+	if len(args) == 0 {
+		cli.Usage(os.Stdout)
+		return 0
+	}
+	cmd, ok := cli.Lookup(args[0])
+	if !ok {
+		msg := "unknown help topic: " + args[0]
+		if s := cli.Suggest(args[0]); s != "" {
+			msg += " (did you mean " + s + "?)"
+		}
+		cli.Report("help", cli.CodeUnknownCommand, errors.New(msg))
+		return 2
+	}
+	cmd.Help(os.Stdout)
+	return 0
 }
@@ -0,0 +1,428 @@
+package ls
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotArchive is returned by ListArchive for files in no supported
+// archive format.
+var ErrNotArchive = errors.New("not a tar, zip or cpio archive")
+
+// IsArchive reports whether the file at path looks like an archive that
+// ListArchive can read.
+func IsArchive(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+	br := bufio.NewReader(f)
+	format, _ := sniffArchive(br)
+	if format == "gzip" || format == "bzip2" {
+		// Only a compressed tar or cpio archive is one.
+		format, _, _ = sniffCompressed(br, format)
+		return format == "tar" || format == "cpio"
+	}
+	return format != ""
+}
+
+// sniffArchive names the format of the archive at the start of br:
+// "zip", "gzip", "bzip2", "tar" or "cpio", or "" if it is none of them.
+func sniffArchive(br *bufio.Reader) (string, error) {
+	buf, err := br.Peek(512)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	switch {
+	case bytes.HasPrefix(buf, []byte("PK\x03\x04")), bytes.HasPrefix(buf, []byte("PK\x05\x06")):
+		return "zip", nil
+	case bytes.HasPrefix(buf, []byte{0x1f, 0x8b}):
+		return "gzip", nil
+	case bytes.HasPrefix(buf, []byte("BZh")):
+		return "bzip2", nil
+	case bytes.HasPrefix(buf, []byte("070701")), bytes.HasPrefix(buf, []byte("070702")), bytes.HasPrefix(buf, []byte("070707")):
+		return "cpio", nil
+	case len(buf) >= 262 && bytes.Equal(buf[257:262], []byte("ustar")):
+		return "tar", nil
+	case len(buf) == 512 && tarChecksumOK(buf):
+		return "tar", nil // pre-POSIX tar without the ustar magic
+	}
+	return "", nil
+}
+
+// sniffCompressed names the format of the data compressed in the gzip or
+// bzip2 stream at the start of br, and returns a reader of that data.
+func sniffCompressed(br *bufio.Reader, format string) (string, io.Reader, error) {
+	var r io.Reader
+	if format == "gzip" {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return "", nil, err
+		}
+		r = zr
+	} else {
+		r = bzip2.NewReader(br)
+	}
+	inner := bufio.NewReader(r)
+	format, err := sniffArchive(inner)
+	return format, inner, err
+}
+
+// tarChecksumOK verifies the header checksum of a tar block.
+func tarChecksumOK(b []byte) bool {
+	want, err := strconv.ParseUint(strings.Trim(string(b[148:156]), " \x00"), 8, 64)
+	if err != nil {
+		return false
+	}
+	var sum uint64
+	for i, c := range b {
+		if i >= 148 && i < 156 {
+			c = ' '
+		}
+		sum += uint64(c)
+	}
+	return sum == want
+}
+
+// ListArchive calls emit for each member of the tar (optionally gzip or
+// bzip2 compressed), zip or cpio archive at path, in archive order, without
+// extracting anything. Member entries carry the path inside the archive in
+// Path and the archive's absolute path in Archive.
+//
+// Of opts, only the selection by name applies: HideDotfiles, Include and
+// Exclude, matched against member paths as if walking the archive
+// recursively, and All, which keeps the member for the archive's top
+// directory, if any. Members are not read, so the other options that add
+// details from file contents are ignored.
+func ListArchive(ctx context.Context, path string, opts Options, emit func(Entry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	archive := abs(path)
+	withArchive := func(e Entry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !selectsMember(opts, e.Path) {
+			return nil
+		}
+		e.Archive = archive
+		return emit(e)
+	}
+
+	br := bufio.NewReader(f)
+	format, err := sniffArchive(br)
+	if err != nil {
+		return &fs.PathError{Op: "read", Path: path, Err: err}
+	}
+	var r io.Reader = br
+	switch format {
+	case "zip":
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		return listZip(f, fi.Size(), withArchive)
+	case "gzip", "bzip2":
+		// Look at what was compressed.
+		if format, r, err = sniffCompressed(br, format); err != nil {
+			return &fs.PathError{Op: "read", Path: path, Err: err}
+		}
+	case "":
+		return &fs.PathError{Op: "read", Path: path, Err: ErrNotArchive}
+	}
+
+	switch format {
+	case "tar":
+		err = listTar(r, withArchive)
+	case "cpio":
+		err = listCpio(r, withArchive)
+	default:
+		err = ErrNotArchive
+	}
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return err
+	}
+	return &fs.PathError{Op: "read", Path: path, Err: err}
+}
+
+// selectsMember reports whether opts keep the archive member at the
+// slash-separated path p: like walker.selects, applied to p and, for
+// HideDotfiles and Exclude, to the directories above it.
+func selectsMember(opts Options, p string) bool {
+	if p == "." {
+		return opts.All
+	}
+	parts := strings.Split(p, "/")
+	for i, name := range parts {
+		if opts.HideDotfiles && strings.HasPrefix(name, ".") {
+			return false
+		}
+		if matchAny(opts.Exclude, name, strings.Join(parts[:i+1], "/")) {
+			return false
+		}
+	}
+	return len(opts.Include) == 0 || matchAny(opts.Include, parts[len(parts)-1], p)
+}
+
+// memberEntry builds the Entry of an archive member from what every format
+// records.
+func memberEntry(name string, mode fs.FileMode, size int64, mtime time.Time) Entry {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	t := "file"
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		t = "symlink"
+	case mode.IsDir():
+		t = "dir"
+	case mode&fs.ModeNamedPipe != 0:
+		t = "fifo"
+	case mode&fs.ModeSocket != 0:
+		t = "socket"
+	case mode&fs.ModeCharDevice != 0:
+		t = "char_device"
+	case mode&fs.ModeDevice != 0:
+		t = "block_device"
+	}
+	e := Entry{
+		Name:      path.Base(name),
+		Path:      name,
+		Type:      t,
+		IsDir:     mode.IsDir(),
+		Size:      size,
+		ModeStr:   permString(mode),
+		ModeOctal: fmt.Sprintf("%04o", mode.Perm()),
+	}
+	e.Mtime, e.MtimeUnixNs = timestamp(mtime)
+	return e
+}
+
+func listTar(r io.Reader, emit func(Entry) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e := memberEntry(hdr.Name, hdr.FileInfo().Mode(), hdr.Size, hdr.ModTime)
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			e.LinkTarget = hdr.Linkname
+		case tar.TypeLink:
+			e.Type = "hardlink"
+			e.LinkTarget = hdr.Linkname
+		case tar.TypeChar, tar.TypeBlock:
+			major, minor := uint32(hdr.Devmajor), uint32(hdr.Devminor)
+			e.DeviceMajor, e.DeviceMinor = &major, &minor
+		}
+		e.Uid, e.Gid = uint32(hdr.Uid), uint32(hdr.Gid)
+		e.Owner, e.Group = hdr.Uname, hdr.Gname
+		e.Atime, e.AtimeUnixNs = timestamp(hdr.AccessTime)
+		e.Ctime, e.CtimeUnixNs = timestamp(hdr.ChangeTime)
+		if err := emit(e); err != nil {
+			return err
+		}
+	}
+}
+
+func listZip(f *os.File, size int64, emit func(Entry) error) error {
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return &fs.PathError{Op: "read", Path: f.Name(), Err: err}
+	}
+	for _, zf := range zr.File {
+		fi := zf.FileInfo()
+		e := memberEntry(zf.Name, fi.Mode(), int64(zf.UncompressedSize64), zf.Modified)
+		if e.Type == "symlink" {
+			// Zip stores the link target as the member's contents.
+			if rc, err := zf.Open(); err == nil {
+				b, _ := io.ReadAll(io.LimitReader(rc, 4096))
+				rc.Close()
+				e.LinkTarget = string(b)
+			}
+		}
+		if err := emit(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listCpio reads the "newc" (070701, 070702 with checksums) and portable
+// "odc" (070707) cpio formats.
+func listCpio(r io.Reader, emit func(Entry) error) error {
+	br := bufio.NewReader(r)
+	var off int64 // bytes consumed, for newc's 4-byte alignment
+	read := func(n int64) ([]byte, error) {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(br, b); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		off += n
+		return b, nil
+	}
+	skip := func(n int64) error {
+		d, err := br.Discard(int(n))
+		off += int64(d)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	align := func() error {
+		if pad := (4 - off%4) % 4; pad > 0 {
+			return skip(pad)
+		}
+		return nil
+	}
+	bad := errors.New("malformed cpio header")
+
+	for {
+		magic, err := read(6)
+		if err != nil {
+			return err
+		}
+		var mode, uid, gid, mtime, size, nameSize, rmajor, rminor uint64
+		newc := string(magic) == "070701" || string(magic) == "070702"
+		switch {
+		case newc:
+			hdr, err := read(104)
+			if err != nil {
+				return err
+			}
+			var fields [13]uint64
+			for i := range fields {
+				if fields[i], err = strconv.ParseUint(string(hdr[i*8:i*8+8]), 16, 64); err != nil {
+					return bad
+				}
+			}
+			mode, uid, gid, mtime, size = fields[1], fields[2], fields[3], fields[5], fields[6]
+			rmajor, rminor, nameSize = fields[9], fields[10], fields[11]
+		case string(magic) == "070707":
+			hdr, err := read(70)
+			if err != nil {
+				return err
+			}
+			field := func(at, n int) uint64 {
+				v, perr := strconv.ParseUint(string(hdr[at:at+n]), 8, 64)
+				if perr != nil {
+					err = bad
+				}
+				return v
+			}
+			// dev, ino, mode, uid, gid, nlink, rdev, mtime, namesize, filesize
+			mode, uid, gid = field(12, 6), field(18, 6), field(24, 6)
+			rdev := field(36, 6)
+			mtime, nameSize, size = field(42, 11), field(53, 6), field(59, 11)
+			rmajor, rminor = rdev>>8, rdev&0xff
+			if err != nil {
+				return err
+			}
+		default:
+			return bad
+		}
+		if nameSize == 0 || nameSize > 1<<16 {
+			return bad
+		}
+		nameBuf, err := read(int64(nameSize))
+		if err != nil {
+			return err
+		}
+		name := string(bytes.TrimRight(nameBuf, "\x00"))
+		if newc {
+			if err := align(); err != nil {
+				return err
+			}
+		}
+		if name == "TRAILER!!!" {
+			return nil
+		}
+
+		e := memberEntry(name, unixMode(uint32(mode)), int64(size), time.Unix(int64(mtime), 0))
+		e.Uid, e.Gid = uint32(uid), uint32(gid)
+		if e.Type == "symlink" && size <= 4096 {
+			target, err := read(int64(size))
+			if err != nil {
+				return err
+			}
+			e.LinkTarget = string(target)
+		} else if err := skip(int64(size)); err != nil {
+			return err
+		}
+		if newc {
+			if err := align(); err != nil {
+				return err
+			}
+		}
+		if e.Type == "char_device" || e.Type == "block_device" {
+			major, minor := uint32(rmajor), uint32(rminor)
+			e.DeviceMajor, e.DeviceMinor = &major, &minor
+		}
+		if err := emit(e); err != nil {
+			return err
+		}
+	}
+}
+
+// unixMode converts st_mode bits to an fs.FileMode.
+func unixMode(m uint32) fs.FileMode {
+	mode := fs.FileMode(m & 0o777)
+	switch m & 0o170000 {
+	case 0o040000:
+		mode |= fs.ModeDir
+	case 0o120000:
+		mode |= fs.ModeSymlink
+	case 0o010000:
+		mode |= fs.ModeNamedPipe
+	case 0o140000:
+		mode |= fs.ModeSocket
+	case 0o020000:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case 0o060000:
+		mode |= fs.ModeDevice
+	}
+	if m&0o4000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if m&0o2000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if m&0o1000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
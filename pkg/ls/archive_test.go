@@ -0,0 +1,126 @@
+package ls
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeTar writes a tar archive of the named members, directories ending
+// in a slash, optionally gzip compressed.
+func writeTar(t *testing.T, name string, gz bool, members ...string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, m := range members {
+		hdr := &tar.Header{Name: m, Mode: 0o644, Typeflag: tar.TypeReg, ModTime: time.Unix(1700000000, 0)}
+		if m[len(m)-1] == '/' {
+			hdr.Mode, hdr.Typeflag = 0o755, tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if gz {
+		data = gzipped(t, data)
+	}
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestIsArchive(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "notes.txt.gz")
+	if err := os.WriteFile(plain, gzipped(t, bytes.Repeat([]byte("just some text\n"), 100)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	text := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(text, []byte("just some text\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{writeTar(t, "a.tar", false, "a.go"), true},
+		{writeTar(t, "a.tar.gz", true, "a.go"), true},
+		{plain, false},
+		{text, false},
+		{dir, false},
+	}
+	for _, tt := range tests {
+		if got := IsArchive(tt.path); got != tt.want {
+			t.Errorf("IsArchive(%s) = %v, want %v", filepath.Base(tt.path), got, tt.want)
+		}
+	}
+}
+
+func TestListArchiveSelection(t *testing.T) {
+	archive := writeTar(t, "src.tar.gz", true,
+		"./", "./a.go", "./.env", "./dir/", "./dir/b.go", "./dir/c.txt", "./.git/", "./.git/config")
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"default", Options{}, []string{"a.go", ".env", "dir", "dir/b.go", "dir/c.txt", ".git", ".git/config"}},
+		{"all", Options{All: true}, []string{".", "a.go", ".env", "dir", "dir/b.go", "dir/c.txt", ".git", ".git/config"}},
+		{"hide dotfiles", Options{HideDotfiles: true}, []string{"a.go", "dir", "dir/b.go", "dir/c.txt"}},
+		{"include", Options{Include: []string{"*.go"}}, []string{"a.go", "dir/b.go"}},
+		{"exclude dir", Options{Exclude: []string{"dir"}}, []string{"a.go", ".env", ".git", ".git/config"}},
+		{"exclude path", Options{Exclude: []string{"dir/*.txt", ".git"}}, []string{"a.go", ".env", "dir", "dir/b.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := ListArchive(context.Background(), archive, tt.opts, func(e Entry) error {
+				got = append(got, e.Path)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("members %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListArchiveCompressedNonArchive(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "notes.txt.gz")
+	if err := os.WriteFile(p, gzipped(t, []byte("just some text\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := ListArchive(context.Background(), p, Options{}, func(Entry) error { return nil })
+	if err == nil || !errors.Is(err, ErrNotArchive) {
+		t.Errorf("ListArchive of a plain .gz file: %v, want ErrNotArchive", err)
+	}
+}
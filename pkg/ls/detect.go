@@ -0,0 +1,267 @@
+package ls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// sniffLen is how much of a file detect looks at. It covers the tar header
+// magic at offset 257 and enough text to judge its encoding.
+const sniffLen = 1024
+
+// detect fills in MimeType, Kind, Arch and Encoding of e from the contents
+// of the regular file at path.
+func detect(e *Entry, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	buf = buf[:n]
+
+	switch {
+	case n == 0:
+		e.Kind, e.MimeType = "empty", "inode/x-empty"
+	case bytes.HasPrefix(buf, []byte("\x7fELF")):
+		e.Kind = "elf"
+		e.MimeType, e.Arch = elfInfo(buf)
+	case machoMagic(buf):
+		e.Kind, e.MimeType = "macho", "application/x-mach-binary"
+		e.Arch = machoArch(buf)
+	case bytes.HasPrefix(buf, []byte("MZ")):
+		if arch, ok := peArch(f, buf); ok {
+			e.Kind, e.MimeType, e.Arch = "pe", "application/vnd.microsoft.portable-executable", arch
+		} else {
+			e.Kind, e.MimeType = "data", "application/x-dosexec"
+		}
+	case bytes.HasPrefix(buf, []byte{0x1f, 0x8b}):
+		e.Kind, e.MimeType = "gzip", "application/gzip"
+	case bytes.HasPrefix(buf, []byte("PK\x03\x04")), bytes.HasPrefix(buf, []byte("PK\x05\x06")):
+		e.Kind, e.MimeType = "zip", "application/zip"
+	case n >= 262 && bytes.Equal(buf[257:262], []byte("ustar")):
+		e.Kind, e.MimeType = "tar", "application/x-tar"
+	case bytes.HasPrefix(buf, []byte("\x89PNG\r\n\x1a\n")):
+		e.Kind, e.MimeType = "png", "image/png"
+	case bytes.HasPrefix(buf, []byte{0xff, 0xd8, 0xff}):
+		e.Kind, e.MimeType = "jpeg", "image/jpeg"
+	case bytes.HasPrefix(buf, []byte("%PDF-")):
+		e.Kind, e.MimeType = "pdf", "application/pdf"
+	default:
+		enc := textEncoding(buf, n == sniffLen)
+		if enc == "" {
+			e.Kind, e.MimeType = "data", "application/octet-stream"
+			break
+		}
+		e.Kind, e.MimeType, e.Encoding = "text", "text/plain", enc
+		if bytes.HasPrefix(buf, []byte("#!")) {
+			e.Kind, e.MimeType = "script", scriptMime(buf)
+		}
+	}
+	return nil
+}
+
+var elfMachines = map[uint16]string{
+	3:   "386",
+	8:   "mips",
+	20:  "ppc",
+	21:  "ppc64",
+	22:  "s390x",
+	40:  "arm",
+	62:  "amd64",
+	183: "arm64",
+	243: "riscv",
+	258: "loong64",
+}
+
+// elfInfo returns the MIME type from the ELF object type and the GOARCH
+// style architecture from the machine field.
+func elfInfo(buf []byte) (mime, arch string) {
+	mime = "application/x-elf"
+	if len(buf) < 20 {
+		return mime, ""
+	}
+	var bo binary.ByteOrder = binary.LittleEndian
+	if buf[5] == 2 {
+		bo = binary.BigEndian
+	}
+	switch bo.Uint16(buf[16:]) {
+	case 1:
+		mime = "application/x-object"
+	case 2:
+		mime = "application/x-executable"
+	case 3:
+		mime = "application/x-sharedlib"
+	case 4:
+		mime = "application/x-coredump"
+	}
+	arch = elfMachines[bo.Uint16(buf[18:])]
+	is64 := buf[4] == 2
+	switch {
+	case arch == "riscv" && is64:
+		arch = "riscv64"
+	case arch == "ppc64" && bo == binary.LittleEndian:
+		arch = "ppc64le"
+	case arch == "mips" && is64:
+		arch = "mips64"
+	}
+	if arch == "mips" || arch == "mips64" {
+		if bo == binary.LittleEndian {
+			arch += "le"
+		}
+	}
+	return mime, arch
+}
+
+var machoCPUs = map[uint32]string{
+	7:          "386",
+	0x01000007: "amd64",
+	12:         "arm",
+	0x0100000c: "arm64",
+	18:         "ppc",
+	0x01000012: "ppc64",
+}
+
+func machoMagic(buf []byte) bool {
+	if len(buf) < 8 {
+		return false
+	}
+	switch binary.BigEndian.Uint32(buf) {
+	case 0xfeedface, 0xfeedfacf, 0xcefaedfe, 0xcffaedfe:
+		return true
+	case 0xcafebabe:
+		// Universal binaries share their magic with Java class files,
+		// whose version number follows where the arch count would.
+		nArch := binary.BigEndian.Uint32(buf[4:])
+		return nArch > 0 && nArch < 20
+	}
+	return false
+}
+
+// machoArch returns the architecture of a thin Mach-O file, or the
+// comma-separated architectures of a universal one.
+func machoArch(buf []byte) string {
+	magic := binary.BigEndian.Uint32(buf)
+	if magic == 0xcafebabe {
+		var archs []string
+		nArch := int(binary.BigEndian.Uint32(buf[4:]))
+		for i := 0; i < nArch && 8+i*20+4 <= len(buf); i++ {
+			if a := machoCPUs[binary.BigEndian.Uint32(buf[8+i*20:])]; a != "" {
+				archs = append(archs, a)
+			}
+		}
+		return strings.Join(archs, ",")
+	}
+	var bo binary.ByteOrder = binary.BigEndian
+	if magic == 0xcefaedfe || magic == 0xcffaedfe {
+		bo = binary.LittleEndian
+	}
+	return machoCPUs[bo.Uint32(buf[4:])]
+}
+
+var peMachines = map[uint16]string{
+	0x014c: "386",
+	0x8664: "amd64",
+	0x01c0: "arm",
+	0x01c4: "arm",
+	0xaa64: "arm64",
+	0x5064: "riscv64",
+}
+
+// peArch follows the DOS header to the PE header and returns the
+// architecture from its machine field. ok is false for plain DOS programs.
+func peArch(f *os.File, buf []byte) (arch string, ok bool) {
+	if len(buf) < 0x40 {
+		return "", false
+	}
+	off := int64(binary.LittleEndian.Uint32(buf[0x3c:]))
+	var hdr [6]byte
+	if off+6 <= int64(len(buf)) {
+		copy(hdr[:], buf[off:])
+	} else if _, err := f.ReadAt(hdr[:], off); err != nil {
+		return "", false
+	}
+	if !bytes.Equal(hdr[:4], []byte("PE\x00\x00")) {
+		return "", false
+	}
+	return peMachines[binary.LittleEndian.Uint16(hdr[4:])], true
+}
+
+// textEncoding returns the encoding of buf if it looks like text, or ""
+// for binary data. truncated means buf may end in the middle of a rune.
+func textEncoding(buf []byte, truncated bool) string {
+	switch {
+	case bytes.HasPrefix(buf, []byte{0xef, 0xbb, 0xbf}):
+		return "utf-8"
+	case bytes.HasPrefix(buf, []byte{0xff, 0xfe}):
+		return "utf-16le"
+	case bytes.HasPrefix(buf, []byte{0xfe, 0xff}):
+		return "utf-16be"
+	}
+	ascii := true
+	for i := 0; i < len(buf); {
+		c := buf[i]
+		if c < utf8.RuneSelf {
+			if c < 0x20 && !strings.ContainsRune("\t\n\r\f\v\b\x1b", rune(c)) || c == 0x7f {
+				return ""
+			}
+			i++
+			continue
+		}
+		ascii = false
+		r, size := utf8.DecodeRune(buf[i:])
+		if r == utf8.RuneError && size <= 1 {
+			if truncated && !utf8.FullRune(buf[i:]) {
+				break
+			}
+			return ""
+		}
+		i += size
+	}
+	if ascii {
+		return "us-ascii"
+	}
+	return "utf-8"
+}
+
+var scriptMimes = map[string]string{
+	"sh":      "text/x-shellscript",
+	"bash":    "text/x-shellscript",
+	"dash":    "text/x-shellscript",
+	"zsh":     "text/x-shellscript",
+	"python":  "text/x-script.python",
+	"python3": "text/x-script.python",
+	"perl":    "text/x-perl",
+	"ruby":    "text/x-ruby",
+	"node":    "text/javascript",
+}
+
+// scriptMime returns the MIME type for the interpreter named in a shebang
+// line, looking through "/usr/bin/env".
+func scriptMime(buf []byte) string {
+	line, _, _ := bytes.Cut(buf[2:], []byte("\n"))
+	args := strings.Fields(string(line))
+	if len(args) > 0 && filepath.Base(args[0]) == "env" {
+		args = args[1:]
+		for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+			args = args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return "text/plain"
+	}
+	if m, ok := scriptMimes[filepath.Base(args[0])]; ok {
+		return m
+	}
+	return "text/plain"
+}
@@ -0,0 +1,325 @@
+package ls
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/antonmedv/jout/internal/git"
+)
+
+// Git is the state of an entry in the git repository containing it.
+type Git struct {
+	Status       string `json:"status"`                  // tracked|untracked|ignored
+	Staged       bool   `json:"staged"`                  // the index differs from HEAD (for directories, anywhere below)
+	Modified     bool   `json:"modified"`                // the work tree differs from the index (for directories, anywhere below)
+	Commit       string `json:"commit,omitempty"`        // last commit that changed the path
+	CommitAuthor string `json:"commit_author,omitempty"` // author of that commit, "Name <email>"
+	CommitTime   string `json:"commit_time,omitempty"`   // author time of that commit, RFC3339 UTC
+}
+
+// gitAnnotator fills in Entry.Git, keeping the repositories it has opened
+// for the rest of the walk.
+type gitAnnotator struct {
+	repos map[string]*gitRepo // by work tree
+	dirs  map[string]*gitRepo // by listed directory; nil outside any repository
+
+	// subtree makes the first directory annotated in a repository look up
+	// the last commits of everything below it in the same history walk,
+	// for walks that go on to list those directories.
+	subtree bool
+}
+
+type gitRepo struct {
+	repo  *git.Repo
+	index *git.Index
+	head  git.ID
+	tree  map[string]git.TreeEntry // files at HEAD
+	dirs  map[string]bool          // directories at HEAD
+
+	modified map[string]bool        // work tree check results by path
+	last     map[string]*git.Commit // last commits by path; nil if none was found
+
+	ignores map[string][]*ignoreFile // .gitignore files by directory
+}
+
+func newGitAnnotator(subtree bool) *gitAnnotator {
+	return &gitAnnotator{repos: map[string]*gitRepo{}, dirs: map[string]*gitRepo{}, subtree: subtree}
+}
+
+// close releases the files of the repositories opened during the walk.
+func (g *gitAnnotator) close() {
+	for _, r := range g.repos {
+		r.repo.Close()
+	}
+}
+
+// open returns the repository containing the directory dir, or nil.
+func (g *gitAnnotator) open(dir string) (*gitRepo, error) {
+	if r, ok := g.dirs[dir]; ok {
+		return r, nil
+	}
+	repo, err := git.Discover(dir)
+	if errors.Is(err, git.ErrNotRepository) {
+		g.dirs[dir] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := g.repos[repo.WorkTree]; ok {
+		g.dirs[dir] = r
+		return r, nil
+	}
+
+	r := &gitRepo{repo: repo, dirs: map[string]bool{}, modified: map[string]bool{}, last: map[string]*git.Commit{}, ignores: map[string][]*ignoreFile{}}
+	if err := r.load(); err != nil {
+		repo.Close()
+		return nil, err
+	}
+	g.repos[repo.WorkTree] = r
+	g.dirs[dir] = r
+	return r, nil
+}
+
+// load reads the index and the files and directories at HEAD.
+func (r *gitRepo) load() (err error) {
+	if r.index, err = r.repo.Index(); err != nil {
+		return err
+	}
+	if r.head, err = r.repo.Head(); err != nil {
+		return err
+	}
+	r.tree = map[string]git.TreeEntry{}
+	if !r.head.IsZero() {
+		c, err := r.repo.Commit(r.head)
+		if err != nil {
+			return err
+		}
+		if r.tree, err = r.repo.Files(c.Tree); err != nil {
+			return err
+		}
+	}
+	for p := range r.tree {
+		for i := strings.LastIndexByte(p, '/'); i > 0; i = strings.LastIndexByte(p, '/') {
+			p = p[:i]
+			if r.dirs[p] {
+				break
+			}
+			r.dirs[p] = true
+		}
+	}
+	return nil
+}
+
+// annotate sets Git on the items listed from the directory dir.
+func (g *gitAnnotator) annotate(dir string, items []Entry, report func(error)) {
+	real, err := filepath.EvalSymlinks(abs(dir))
+	if err != nil {
+		report(err)
+		return
+	}
+	r, err := g.open(real)
+	if err != nil {
+		report(err)
+	}
+	if r == nil {
+		return
+	}
+	relDir, err := filepath.Rel(r.repo.WorkTree, real)
+	if err != nil {
+		return
+	}
+	relDir = filepath.ToSlash(relDir)
+	if relDir == "." {
+		relDir = ""
+	}
+	if relDir == ".git" || strings.HasPrefix(relDir, ".git/") {
+		return
+	}
+
+	var committed []string
+	byPath := map[string]*Entry{}
+	for i := range items {
+		e := &items[i]
+		if e.Name == "." || e.Name == ".." || (relDir == "" && e.Name == ".git") {
+			continue
+		}
+		rel := path.Join(relDir, e.Name)
+		info, err := os.Lstat(filepath.Join(real, e.Name))
+		if err != nil {
+			report(err)
+			continue
+		}
+		gi, err := r.status(rel, filepath.Join(real, e.Name), info)
+		if err != nil {
+			report(err)
+		}
+		e.Git = gi
+		if r.committed(rel, info.IsDir()) {
+			committed = append(committed, rel)
+			byPath[rel] = e
+		}
+	}
+
+	if err := r.lastCommits(committed, relDir, g.subtree); err != nil {
+		report(err)
+		return
+	}
+	for rel, e := range byPath {
+		c := r.last[rel]
+		if c == nil {
+			continue
+		}
+		e.Git.Commit = c.ID.String()
+		e.Git.CommitAuthor = c.Author
+		if !c.AuthorTime.IsZero() {
+			e.Git.CommitTime = c.AuthorTime.Format(time.RFC3339)
+		}
+	}
+}
+
+// lastCommits fills r.last for the paths not looked up yet, and with
+// subtree for everything at HEAD below the directory relDir too, so that
+// a recursive walk goes through history once.
+func (r *gitRepo) lastCommits(paths []string, relDir string, subtree bool) error {
+	want := map[string]bool{}
+	for _, p := range paths {
+		if _, ok := r.last[p]; !ok {
+			want[p] = true
+		}
+	}
+	if len(want) == 0 {
+		return nil
+	}
+	if subtree {
+		below := func(p string) bool {
+			_, ok := r.last[p]
+			return !ok && (relDir == "" || strings.HasPrefix(p, relDir+"/"))
+		}
+		for p := range r.dirs {
+			if below(p) {
+				want[p] = true
+			}
+		}
+		for p := range r.tree {
+			if below(p) {
+				want[p] = true
+			}
+		}
+	}
+	missing := slices.Collect(maps.Keys(want))
+	last, err := r.repo.LastCommits(r.head, missing)
+	if err != nil {
+		return err
+	}
+	for _, p := range missing {
+		r.last[p] = last[p]
+	}
+	return nil
+}
+
+// committed reports whether rel exists in the HEAD commit.
+func (r *gitRepo) committed(rel string, isDir bool) bool {
+	if isDir {
+		return r.dirs[rel]
+	}
+	_, ok := r.tree[rel]
+	return ok
+}
+
+// status compares the file at full path, at rel in the work tree, with the
+// index and HEAD.
+func (r *gitRepo) status(rel, full string, info os.FileInfo) (*Git, error) {
+	gi := &Git{}
+	var entries []git.IndexEntry
+	if info.IsDir() {
+		entries = r.index.Under(rel)
+	} else if e, ok := r.index.Find(rel); ok {
+		entries = []git.IndexEntry{*e}
+	}
+	if len(entries) == 0 {
+		gi.Status = "untracked"
+		if r.ignored(rel, info.IsDir()) {
+			gi.Status = "ignored"
+		}
+		// Still in HEAD: its removal from the index is staged.
+		gi.Staged = r.committed(rel, info.IsDir())
+		return gi, nil
+	}
+
+	gi.Status = "tracked"
+	var firstErr error
+	for i := range entries {
+		e := &entries[i]
+		if h, ok := r.tree[e.Path]; !ok || h.ID != e.ID || h.Mode != e.Mode || e.Stage != 0 {
+			gi.Staged = true
+		}
+		if gi.Modified {
+			continue
+		}
+		p, fi := full, info
+		if info.IsDir() {
+			p = filepath.Join(full, filepath.FromSlash(strings.TrimPrefix(e.Path, rel+"/")))
+			var err error
+			if fi, err = os.Lstat(p); err != nil {
+				gi.Modified = true // deleted from the work tree
+				continue
+			}
+		}
+		m, ok := r.modified[e.Path]
+		if !ok {
+			var err error
+			if m, err = r.index.Modified(e, p, fi); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			r.modified[e.Path] = m
+		}
+		gi.Modified = m
+	}
+	return gi, firstErr
+}
+
+// ignored reports whether rel or one of its parent directories is
+// excluded by .git/info/exclude or a .gitignore file.
+func (r *gitRepo) ignored(rel string, isDir bool) bool {
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		sub := strings.Join(parts[:i+1], "/")
+		dir := strings.Join(parts[:i], "/")
+		if ignoredBy(r.ignoreFiles(dir), sub, isDir || i < len(parts)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreFiles returns the exclude files that apply in the directory dir:
+// .git/info/exclude and the .gitignore files from the top down.
+func (r *gitRepo) ignoreFiles(dir string) []*ignoreFile {
+	if files, ok := r.ignores[dir]; ok {
+		return files
+	}
+	var files []*ignoreFile
+	if dir == "" {
+		if ig, _ := loadIgnoreFile(filepath.Join(r.repo.CommonDir, "info", "exclude"), ""); ig != nil {
+			files = append(files, ig)
+		}
+	} else {
+		parent := ""
+		if i := strings.LastIndexByte(dir, '/'); i >= 0 {
+			parent = dir[:i]
+		}
+		files = append(files, r.ignoreFiles(parent)...)
+	}
+	if ig, _ := loadIgnore(filepath.Join(r.repo.WorkTree, filepath.FromSlash(dir)), dir); ig != nil {
+		files = append(files, ig)
+	}
+	r.ignores[dir] = files
+	return files
+}
@@ -0,0 +1,111 @@
+package ls
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/antonmedv/jout/internal/blake2b"
+)
+
+// HashAlgorithms are the names accepted in Options.Hash.
+var HashAlgorithms = []string{"md5", "sha1", "sha256", "sha512", "blake2b"}
+
+var hashFuncs = map[string]func() hash.Hash{
+	"md5":     md5.New,
+	"sha1":    sha1.New,
+	"sha256":  sha256.New,
+	"sha512":  sha512.New,
+	"blake2b": blake2b.New512,
+}
+
+// hashPool computes Entry.Hashes with at most a fixed number of files
+// hashed at once, shared by every directory of a walk.
+type hashPool struct {
+	algs    []string
+	maxSize int64
+	sem     chan struct{}
+}
+
+func newHashPool(opts Options) (*hashPool, error) {
+	if len(opts.Hash) == 0 {
+		return nil, nil
+	}
+	for _, alg := range opts.Hash {
+		if hashFuncs[alg] == nil {
+			return nil, fmt.Errorf("unknown hash algorithm %q", alg)
+		}
+	}
+	workers := opts.HashWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	return &hashPool{algs: opts.Hash, maxSize: opts.HashMaxSize, sem: make(chan struct{}, workers)}, nil
+}
+
+// hashAll fills in the hashes of the regular files among items in
+// parallel. Files that cannot be read are passed to report and keep no
+// hashes; files over the size cap are marked with HashesSkipped.
+func (p *hashPool) hashAll(ctx context.Context, items []Entry, infos map[string]os.FileInfo, report func(error)) error {
+	var wg sync.WaitGroup
+	for i := range items {
+		info := infos[items[i].Name]
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if p.maxSize > 0 && info.Size() > p.maxSize {
+			items[i].HashesSkipped = "size"
+			continue
+		}
+		select {
+		case p.sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func(e *Entry) {
+			defer func() { <-p.sem; wg.Done() }()
+			sums, err := p.hashFile(e.Path)
+			if err != nil {
+				report(err)
+				return
+			}
+			e.Hashes = sums
+		}(&items[i])
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// hashFile reads the file at path once, feeding every algorithm.
+func (p *hashPool) hashFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hs := make([]hash.Hash, len(p.algs))
+	ws := make([]io.Writer, len(p.algs))
+	for i, alg := range p.algs {
+		hs[i] = hashFuncs[alg]()
+		ws[i] = hs[i]
+	}
+	if _, err := io.Copy(io.MultiWriter(ws...), f); err != nil {
+		return nil, err
+	}
+	sums := make(map[string]string, len(p.algs))
+	for i, alg := range p.algs {
+		sums[alg] = hex.EncodeToString(hs[i].Sum(nil))
+	}
+	return sums, nil
+}
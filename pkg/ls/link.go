@@ -0,0 +1,80 @@
+package ls
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Link describes how a symlink resolves.
+type Link struct {
+	Target      string   `json:"target"`       // absolute path the link finally resolves to, or the last path reached if broken or looping
+	Chain       []string `json:"chain"`        // absolute path reached at each hop, ending with target
+	Broken      bool     `json:"broken"`       // some hop points to a path that does not exist
+	Loop        bool     `json:"loop"`         // the links form a cycle
+	OutsideRoot bool     `json:"outside_root"` // target is not within the listed path
+}
+
+// maxLinkHops matches the kernel's limit on nested symlinks (MAXSYMLINKS).
+const maxLinkHops = 40
+
+// resolveLink follows the symlink at path hop by hop. root is the listed
+// path with its own symlinks resolved.
+func resolveLink(path, root string) *Link {
+	l := &Link{Chain: make([]string, 0, 1)}
+	seen := map[string]bool{}
+	// Relative targets are relative to the link's real directory, so
+	// resolve that before joining them lexically.
+	cur := path
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		cur = filepath.Join(dir, filepath.Base(path))
+	}
+	for {
+		if len(l.Chain) == maxLinkHops || seen[cur] {
+			l.Loop = true
+			break
+		}
+		seen[cur] = true
+
+		target, err := os.Readlink(cur)
+		if err != nil {
+			l.Broken = true
+			break
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(cur), target)
+		}
+		// Symlinks among the parent directories resolve as part of this hop.
+		dir, err := filepath.EvalSymlinks(filepath.Dir(target))
+		if err != nil {
+			l.Target = target
+			l.Chain = append(l.Chain, target)
+			if errors.Is(err, syscall.ELOOP) {
+				l.Loop = true
+			} else {
+				l.Broken = true
+			}
+			break
+		}
+		next := filepath.Join(dir, filepath.Base(target))
+		l.Target = next
+		l.Chain = append(l.Chain, next)
+
+		fi, err := os.Lstat(next)
+		if err != nil {
+			l.Broken = true
+			break
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			break
+		}
+		cur = next
+	}
+	if l.Target != "" && root != "" {
+		rel, err := filepath.Rel(root, l.Target)
+		l.OutsideRoot = err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	}
+	return l
+}
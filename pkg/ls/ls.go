@@ -0,0 +1,634 @@
+// Package ls lists file system entries with the metadata reported by
+// `jout ls`.
+package ls
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// Entry is a single file system entry as reported by jout ls.
+type Entry struct {
+	Name       string `json:"name"`                  // base name
+	Path       string `json:"path"`                  // absolute path, or the path inside the archive for archive members
+	Type       string `json:"type"`                  // file|dir|symlink|fifo|socket|block_device|char_device|hardlink (archive members only)
+	IsDir      bool   `json:"is_dir"`                // true for directories (and followed links to them)
+	LinkTarget string `json:"link_target,omitempty"` // raw symlink target as stored in the link
+	Link       *Link  `json:"link,omitempty"`        // how the symlink resolves
+	Size       int64  `json:"size_bytes"`            // size in bytes
+	ModeStr    string `json:"mode_str"`              // ls-style mode, e.g. "-rw-r--r--"
+	ModeOctal  string `json:"mode_octal"`            // permission bits in octal, e.g. "0644"
+	Inode      uint64 `json:"inode,omitempty"`
+	Dev        uint64 `json:"dev"` // id of the file system device containing the entry
+
+	DeviceMajor *uint32 `json:"device_major,omitempty"` // major number, for block and char devices
+	DeviceMinor *uint32 `json:"device_minor,omitempty"` // minor number, for block and char devices
+
+	Nlink uint64 `json:"nlink,omitempty"` // number of hard links
+	Uid   uint32 `json:"uid,omitempty"`
+	Gid   uint32 `json:"gid,omitempty"`
+	Owner string `json:"owner,omitempty"` // user name of uid
+	Group string `json:"group,omitempty"` // group name of gid
+	Mtime string `json:"mtime"`           // modification time, RFC3339 UTC with nanoseconds
+	Atime string `json:"atime,omitempty"` // access time, RFC3339 UTC with nanoseconds
+	Ctime string `json:"ctime,omitempty"` // status change time, RFC3339 UTC with nanoseconds
+	Btime string `json:"btime,omitempty"` // birth (creation) time where the file system records it
+
+	MtimeUnixNs int64 `json:"mtime_unix_ns"`           // mtime as nanoseconds since the Unix epoch
+	AtimeUnixNs int64 `json:"atime_unix_ns,omitempty"` // atime as nanoseconds since the Unix epoch
+	CtimeUnixNs int64 `json:"ctime_unix_ns,omitempty"` // ctime as nanoseconds since the Unix epoch
+	BtimeUnixNs int64 `json:"btime_unix_ns,omitempty"` // btime as nanoseconds since the Unix epoch
+
+	Blocks     int64    `json:"blocks,omitempty"`     // 512-byte blocks allocated
+	Blksize    int64    `json:"blksize,omitempty"`    // preferred I/O block size
+	Attributes []string `json:"attributes,omitempty"` // statx attributes set on the file, e.g. ["immutable", "append"] (Linux)
+	MountID    uint64   `json:"mount_id,omitempty"`   // id of the mount containing the entry, as in /proc/self/mountinfo (Linux)
+
+	MimeType       string            `json:"mime_type,omitempty"`       // MIME type sniffed from contents, with ls --detect
+	Kind           string            `json:"kind,omitempty"`            // elf|macho|pe|gzip|zip|tar|png|jpeg|pdf|script|text|data|empty
+	Arch           string            `json:"arch,omitempty"`            // GOARCH-style architecture of executables; comma-separated for universal binaries
+	Encoding       string            `json:"encoding,omitempty"`        // us-ascii|utf-8|utf-16le|utf-16be, for text and scripts
+	Xattrs         map[string]string `json:"xattrs,omitempty"`          // base64 values by attribute name, with ls --xattr (Linux)
+	ACL            *ACL              `json:"acl,omitempty"`             // decoded POSIX ACLs
+	Capabilities   *Capabilities     `json:"capabilities,omitempty"`    // decoded file capabilities
+	SELinuxContext string            `json:"selinux_context,omitempty"` // e.g. "system_u:object_r:bin_t:s0"
+	Git            *Git              `json:"git,omitempty"`             // state in the containing git repository, with ls --git
+	Hashes         map[string]string `json:"hashes,omitempty"`          // hex digests by algorithm, with ls --hash
+	HashesSkipped  string            `json:"hashes_skipped,omitempty"`  // why a regular file has no hashes: "size" if over ls --hash-max-size
+	Archive        string            `json:"archive,omitempty"`         // absolute path of the archive, for archive members
+	Children       []Entry           `json:"children,omitempty"`        // directory contents, with ls --tree
+}
+
+func makeEntry(name, fullPath string, info os.FileInfo) Entry {
+	// Determine type
+	t := "file"
+	switch m := info.Mode(); {
+	case m&os.ModeSymlink != 0:
+		t = "symlink"
+	case info.IsDir():
+		t = "dir"
+	case m&os.ModeNamedPipe != 0:
+		t = "fifo"
+	case m&os.ModeSocket != 0:
+		t = "socket"
+	case m&os.ModeCharDevice != 0:
+		t = "char_device"
+	case m&os.ModeDevice != 0:
+		t = "block_device"
+	}
+
+	var linkTarget string
+	if t == "symlink" {
+		if lt, err := os.Readlink(fullPath); err == nil {
+			linkTarget = lt
+		}
+	}
+
+	m := info.Mode()
+	x := getExtra(info)
+	e := Entry{
+		Name:       name,
+		Path:       fullPath,
+		Type:       t,
+		IsDir:      info.IsDir(),
+		Size:       info.Size(),
+		ModeStr:    permString(m),
+		ModeOctal:  fmt.Sprintf("%04o", m.Perm()),
+		Blocks:     x.Blocks,
+		Blksize:    x.Blksize,
+		Inode:      x.Inode,
+		Dev:        x.Dev,
+		Nlink:      x.Nlink,
+		Uid:        x.Uid,
+		Gid:        x.Gid,
+		Owner:      x.Owner,
+		Group:      x.Group,
+		LinkTarget: linkTarget,
+	}
+	e.Mtime, e.MtimeUnixNs = timestamp(info.ModTime())
+	e.Atime, e.AtimeUnixNs = timestamp(x.Atime)
+	e.Ctime, e.CtimeUnixNs = timestamp(x.Ctime)
+	e.Btime, e.BtimeUnixNs = timestamp(x.Btime)
+	if t == "block_device" || t == "char_device" {
+		e.DeviceMajor, e.DeviceMinor = &x.RdevMajor, &x.RdevMinor
+	}
+	return e
+}
+
+// timestamp formats t for Entry, or returns zero values if t is unknown.
+func timestamp(t time.Time) (string, int64) {
+	if t.IsZero() {
+		return "", 0
+	}
+	return t.UTC().Format(time.RFC3339Nano), t.UnixNano()
+}
+
+// FollowMode controls how symlinks are handled
+// P: never follow; H: follow command-line argument only; L: follow everywhere
+type FollowMode int
+
+const (
+	FollowP FollowMode = iota
+	FollowH
+	FollowL
+)
+
+// Options configure List and ListDir.
+type Options struct {
+	Follow FollowMode
+
+	// Unsorted emits directory children in directory order as they are
+	// read, in bounded memory, instead of sorted by name.
+	Unsorted bool
+
+	// Recursive descends into subdirectories, up to MaxDepth levels below
+	// the target when MaxDepth is positive. OneFileSystem keeps the walk on
+	// the target's file system; mount points are listed but not entered.
+	Recursive     bool
+	MaxDepth      int
+	OneFileSystem bool
+
+	// All adds the "." and ".." entries of each listed directory, as with
+	// ls -a. HideDotfiles leaves out names starting with a dot; it is off
+	// by default, unlike ls, so dotfiles are listed as with ls -A.
+	All          bool
+	HideDotfiles bool
+
+	// Directory lists a directory target itself rather than its contents.
+	Directory bool
+
+	// Include keeps only entries whose name matches one of the globs, and
+	// Exclude drops entries matching any of them along with their
+	// contents. Patterns containing a slash match the path relative to
+	// the target instead. Directories left out by Include are still
+	// walked when recursing.
+	Include []string
+	Exclude []string
+
+	// GitIgnore skips entries ignored by .gitignore files in the target
+	// and the directories below it, along with .git directories.
+	GitIgnore bool
+
+	// Detect sniffs the contents of regular files to fill in
+	// Entry.MimeType, Kind, Arch and Encoding.
+	Detect bool
+
+	// Xattr reads extended attributes into Entry.Xattrs, decoding POSIX
+	// ACLs, file capabilities and the SELinux context. Linux only.
+	Xattr bool
+
+	// Git annotates entries inside a git work tree with Entry.Git, read
+	// from the repository directly.
+	Git bool
+
+	// Hash lists algorithms from HashAlgorithms to compute Entry.Hashes
+	// with for regular files, using up to HashWorkers files at a time.
+	// Files larger than HashMaxSize, when positive, are not hashed and have
+	// Entry.HashesSkipped set instead; files that cannot be read are passed
+	// to OnError.
+	Hash        []string
+	HashMaxSize int64
+	HashWorkers int
+
+	// Tree nests the children of each directory in Entry.Children instead
+	// of emitting them after it. Only the target's children are emitted.
+	Tree bool
+
+	// OnError is called for children that cannot be stat'ed and for
+	// subdirectories that cannot be read or would form a cycle. They are
+	// skipped either way.
+	OnError func(error)
+}
+
+// ListDir returns path itself if it is not a directory, or its children
+// otherwise. The error is about path itself; see Options.OnError for
+// children.
+func ListDir(ctx context.Context, path string, opts Options) ([]Entry, error) {
+	items := make([]Entry, 0)
+	err := List(ctx, path, opts, func(e Entry) error {
+		items = append(items, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// abs returns the absolute form of p, or p itself if resolution fails.
+func abs(p string) string {
+	if ap, err := filepath.Abs(p); err == nil {
+		return ap
+	}
+	return p
+}
+
+// permString returns an ls-like permission string (e.g., "-rw-r--r--", "drwxr-xr-x").
+// It includes file type, rwx bits, and suid/sgid/sticky handling.
+func permString(m os.FileMode) string {
+	// Type character
+	typeCh := '-'
+	if m&os.ModeDir != 0 {
+		typeCh = 'd'
+	} else if m&os.ModeSymlink != 0 {
+		typeCh = 'l'
+	} else if m&os.ModeNamedPipe != 0 {
+		typeCh = 'p'
+	} else if m&os.ModeSocket != 0 {
+		typeCh = 's'
+	} else if m&os.ModeDevice != 0 {
+		if m&os.ModeCharDevice != 0 {
+			typeCh = 'c'
+		} else {
+			typeCh = 'b'
+		}
+	}
+
+	// Permission bits
+	perm := m.Perm()
+	chars := [9]byte{'-', '-', '-', '-', '-', '-', '-', '-', '-'}
+	// User
+	if perm&0400 != 0 {
+		chars[0] = 'r'
+	}
+	if perm&0200 != 0 {
+		chars[1] = 'w'
+	}
+	if perm&0100 != 0 {
+		chars[2] = 'x'
+	}
+	// Group
+	if perm&0040 != 0 {
+		chars[3] = 'r'
+	}
+	if perm&0020 != 0 {
+		chars[4] = 'w'
+	}
+	if perm&0010 != 0 {
+		chars[5] = 'x'
+	}
+	// Other
+	if perm&0004 != 0 {
+		chars[6] = 'r'
+	}
+	if perm&0002 != 0 {
+		chars[7] = 'w'
+	}
+	if perm&0001 != 0 {
+		chars[8] = 'x'
+	}
+
+	// suid/sgid/sticky modifications
+	if m&os.ModeSetuid != 0 {
+		if chars[2] == 'x' {
+			chars[2] = 's'
+		} else {
+			chars[2] = 'S'
+		}
+	}
+	if m&os.ModeSetgid != 0 {
+		if chars[5] == 'x' {
+			chars[5] = 's'
+		} else {
+			chars[5] = 'S'
+		}
+	}
+	if m&os.ModeSticky != 0 {
+		if chars[8] == 'x' {
+			chars[8] = 't'
+		} else {
+			chars[8] = 'T'
+		}
+	}
+
+	return string(append([]byte{byte(typeCh)}, chars[:]...))
+}
+
+// readDirBatch bounds memory when directory entries are streamed unsorted.
+const readDirBatch = 1024
+
+// List calls emit for path itself if it is not a directory, or for each of
+// its children otherwise, stopping at the first error emit returns. With
+// Options.Recursive, the children of subdirectories follow their parent
+// depth-first, or are nested under it with Options.Tree.
+func List(ctx context.Context, path string, opts Options, emit func(Entry) error) error {
+	mode := opts.Follow
+	// Determine info for target based on follow mode
+	var info os.FileInfo
+	var err error
+
+	switch mode {
+	case FollowL:
+		info, err = os.Stat(path)
+		if err != nil {
+			// Fallback to Lstat so broken symlinks can still be listed
+			info, err = os.Lstat(path)
+		}
+	case FollowH:
+		info, err = os.Lstat(path)
+		if err == nil && (info.Mode()&os.ModeSymlink) != 0 {
+			if si, serr := os.Stat(path); serr == nil {
+				info = si
+			}
+		}
+	default: // FollowP
+		info, err = os.Lstat(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	hashes, err := newHashPool(opts)
+	if err != nil {
+		return err
+	}
+	for _, p := range slices.Concat(opts.Include, opts.Exclude) {
+		if !ValidPattern(p) {
+			return fmt.Errorf("invalid pattern %q", p)
+		}
+	}
+	wk := &walker{ctx: ctx, opts: opts, hashes: hashes, visited: map[fileKey]bool{}}
+	if opts.Git {
+		wk.git = newGitAnnotator(opts.Recursive && info.IsDir() && !opts.Directory)
+		defer wk.git.close()
+	}
+	wk.top = path
+	wk.root = abs(path)
+	if !info.IsDir() || opts.Directory {
+		wk.root = filepath.Dir(wk.root)
+	}
+	if r, err := filepath.EvalSymlinks(wk.root); err == nil {
+		wk.root = r
+	}
+
+	// Non-directory target, or the directory itself with -d: emit single Entry
+	if !info.IsDir() || opts.Directory {
+		items := []Entry{wk.makeEntry(filepath.Base(path), path, info)}
+		if wk.git != nil {
+			wk.git.annotate(filepath.Dir(path), items, wk.report)
+		}
+		if hashes != nil {
+			if err := hashes.hashAll(ctx, items, map[string]os.FileInfo{items[0].Name: info}, wk.report); err != nil {
+				return err
+			}
+		}
+		return emit(items[0])
+	}
+
+	if dev, ino, ok := fileID(info); ok {
+		wk.rootDev = dev
+		wk.visited[fileKey{dev, ino}] = true
+	}
+	return wk.dir(path, 1, emit)
+}
+
+// fileKey identifies a directory for cycle detection.
+type fileKey struct{ dev, ino uint64 }
+
+// walker carries the state of one (possibly recursive) listing.
+type walker struct {
+	ctx     context.Context
+	opts    Options
+	top     string // target directory as given
+	root    string // target directory with symlinks resolved
+	rootDev uint64
+	hashes  *hashPool
+	git     *gitAnnotator
+
+	// ignores are the .gitignore files from the target down to the
+	// directory being listed.
+	ignores []*ignoreFile
+
+	// visited holds the directories on the current descent path, so that
+	// a symlink followed under -L back to an ancestor is not entered again.
+	visited map[fileKey]bool
+}
+
+// dir lists the children of the directory at path, which sits at the given
+// depth below the target (its children are at depth).
+func (wk *walker) dir(path string, depth int, emit func(Entry) error) error {
+	// Note: opening by original path is fine since symlink to dir is handled at info stage for H/L
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if wk.opts.GitIgnore {
+		ig, err := loadIgnore(path, wk.rel(path))
+		if err != nil {
+			wk.report(err)
+		}
+		if ig != nil {
+			wk.ignores = append(wk.ignores, ig)
+			defer func() { wk.ignores = wk.ignores[:len(wk.ignores)-1] }()
+		}
+	}
+
+	sorted := !wk.opts.Unsorted
+	n := readDirBatch
+	if sorted {
+		n = -1
+	}
+	first := true
+	quiet := map[string]bool{}
+	for {
+		if err := wk.ctx.Err(); err != nil {
+			return err
+		}
+		de, err := f.ReadDir(n)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		items := make([]Entry, 0, len(de)+2)
+		infos := make(map[string]os.FileInfo, len(de)+2)
+		if wk.opts.All && first {
+			for _, name := range []string{".", ".."} {
+				fi, err := os.Stat(filepath.Join(path, name))
+				if err != nil {
+					wk.report(err)
+					continue
+				}
+				items = append(items, wk.makeEntry(name, filepath.Join(path, name), fi))
+				infos[name] = fi
+			}
+		}
+		first = false
+		for _, d := range de {
+			joined := filepath.Join(path, d.Name())
+			var fi os.FileInfo
+			if wk.opts.Follow == FollowL {
+				fi, err = os.Stat(joined)
+				if err != nil {
+					// Fallback to Lstat to at least report symlink itself
+					fi, err = os.Lstat(joined)
+				}
+			} else {
+				fi, err = os.Lstat(joined)
+			}
+			if err != nil {
+				// Skip entries we cannot stat, collect partial results like ls
+				wk.report(err)
+				continue
+			}
+			sel := wk.selects(d.Name(), wk.rel(joined), fi.IsDir())
+			if sel == skip {
+				continue
+			}
+			if sel == descend {
+				quiet[d.Name()] = true
+			}
+			items = append(items, wk.makeEntry(d.Name(), joined, fi))
+			infos[d.Name()] = fi
+		}
+
+		if wk.git != nil {
+			wk.git.annotate(path, items, wk.report)
+		}
+		if wk.hashes != nil {
+			if err := wk.hashes.hashAll(wk.ctx, items, infos, wk.report); err != nil {
+				return err
+			}
+		}
+		if sorted {
+			sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+		}
+		for _, e := range items {
+			if err := wk.entry(e, filepath.Join(path, e.Name), infos[e.Name], depth, !quiet[e.Name], emit); err != nil {
+				return err
+			}
+		}
+		if n < 0 {
+			return nil
+		}
+	}
+}
+
+// entry emits e and, when recursing, the children of the directory it
+// describes: after it in flat mode, or in e.Children in tree mode. A
+// directory that is not shown itself is still walked, and in tree mode
+// emitted if anything below it is.
+func (wk *walker) entry(e Entry, path string, info os.FileInfo, depth int, shown bool, emit func(Entry) error) error {
+	if e.Name == "." || e.Name == ".." || !wk.descend(path, info, depth) {
+		if !shown {
+			return nil
+		}
+		return emit(e)
+	}
+	dev, ino, ok := fileID(info)
+	if ok {
+		wk.visited[fileKey{dev, ino}] = true
+		defer delete(wk.visited, fileKey{dev, ino})
+	}
+
+	if wk.opts.Tree {
+		e.Children = make([]Entry, 0)
+		err := wk.dir(path, depth+1, func(c Entry) error {
+			e.Children = append(e.Children, c)
+			return nil
+		})
+		if err != nil {
+			if cerr := wk.ctx.Err(); cerr != nil {
+				return cerr
+			}
+			wk.report(err)
+		}
+		if !shown && len(e.Children) == 0 {
+			return nil
+		}
+		return emit(e)
+	}
+
+	if shown {
+		if err := emit(e); err != nil {
+			return err
+		}
+	}
+	var emitErr error
+	err := wk.dir(path, depth+1, func(c Entry) error {
+		emitErr = emit(c)
+		return emitErr
+	})
+	if err != nil {
+		if emitErr != nil {
+			return emitErr
+		}
+		if cerr := wk.ctx.Err(); cerr != nil {
+			return cerr
+		}
+		// An unreadable subdirectory does not end the walk.
+		wk.report(err)
+	}
+	return nil
+}
+
+// rel returns path relative to the listed directory, slash-separated.
+func (wk *walker) rel(path string) string {
+	r, err := filepath.Rel(wk.top, path)
+	if err != nil || r == "." {
+		return ""
+	}
+	return filepath.ToSlash(r)
+}
+
+// makeEntry describes the file at path, sniffing its contents if asked to.
+func (wk *walker) makeEntry(name, path string, info os.FileInfo) Entry {
+	e := makeEntry(name, abs(path), info)
+	if e.Type == "symlink" {
+		e.Link = resolveLink(e.Path, wk.root)
+	}
+	if wk.opts.Detect && info.Mode().IsRegular() {
+		if err := detect(&e, path); err != nil {
+			wk.report(err)
+		}
+	}
+	if err := statx(&e, path, info.Mode()&os.ModeSymlink != 0); err != nil {
+		wk.report(err)
+	}
+	if wk.opts.Xattr {
+		if err := readXattrs(&e, path, info.Mode()&os.ModeSymlink != 0); err != nil {
+			wk.report(err)
+		}
+	}
+	return e
+}
+
+// descend reports whether the walk should enter the directory at path.
+func (wk *walker) descend(path string, info os.FileInfo, depth int) bool {
+	if !wk.opts.Recursive || !info.IsDir() {
+		return false
+	}
+	if wk.opts.MaxDepth > 0 && depth >= wk.opts.MaxDepth {
+		return false
+	}
+	dev, ino, ok := fileID(info)
+	if !ok {
+		return true
+	}
+	if wk.opts.OneFileSystem && dev != wk.rootDev {
+		return false
+	}
+	if wk.visited[fileKey{dev, ino}] {
+		wk.report(&fs.PathError{Op: "walk", Path: path, Err: syscall.ELOOP})
+		return false
+	}
+	return true
+}
+
+func (wk *walker) report(err error) {
+	if wk.opts.OnError != nil {
+		wk.opts.OnError(err)
+	}
+}
@@ -0,0 +1,93 @@
+//go:build linux
+
+package ls
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// list returns the children of dir by name.
+func list(t *testing.T, dir string) map[string]Entry {
+	t.Helper()
+	items, err := ListDir(context.Background(), dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]Entry)
+	for _, e := range items {
+		byName[e.Name] = e
+	}
+	return byName
+}
+
+func TestSpecialFileTypes(t *testing.T) {
+	dir := t.TempDir()
+	if err := syscall.Mkfifo(filepath.Join(dir, "fifo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", filepath.Join(dir, "sock"))
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer l.Close()
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var st syscall.Stat_t
+	if err := syscall.Stat(dir, &st); err != nil {
+		t.Fatal(err)
+	}
+	entries := list(t, dir)
+	for name, typ := range map[string]string{"fifo": "fifo", "sock": "socket", "file": "file"} {
+		e, ok := entries[name]
+		if !ok {
+			t.Errorf("%s not listed", name)
+			continue
+		}
+		if e.Type != typ {
+			t.Errorf("%s: Type = %q, want %q", name, e.Type, typ)
+		}
+		if e.Dev != uint64(st.Dev) {
+			t.Errorf("%s: Dev = %d, want %d", name, e.Dev, st.Dev)
+		}
+		if e.DeviceMajor != nil || e.DeviceMinor != nil {
+			t.Errorf("%s: device numbers set on a %s", name, typ)
+		}
+	}
+}
+
+func TestCharDevice(t *testing.T) {
+	entries, err := ListDir(context.Background(), "/dev/null", Options{})
+	if err != nil {
+		t.Skip(err)
+	}
+	e := entries[0]
+	if e.Type != "char_device" {
+		t.Fatalf("Type = %q, want char_device", e.Type)
+	}
+	// /dev/null is device 1:3 on Linux.
+	if e.DeviceMajor == nil || *e.DeviceMajor != 1 || e.DeviceMinor == nil || *e.DeviceMinor != 3 {
+		t.Errorf("device = %v:%v, want 1:3", e.DeviceMajor, e.DeviceMinor)
+	}
+}
+
+func TestDevAlwaysEncoded(t *testing.T) {
+	b, err := json.Marshal(Entry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["dev"]; !ok {
+		t.Errorf("dev omitted from %s", b)
+	}
+}
@@ -0,0 +1,204 @@
+package ls
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ValidPattern reports whether pattern is a valid Include or Exclude glob.
+func ValidPattern(pattern string) bool {
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// matchAny reports whether name or, for patterns containing a slash, the
+// slash-separated path rel matches one of patterns.
+func matchAny(patterns []string, name, rel string) bool {
+	for _, p := range patterns {
+		subject := name
+		if strings.Contains(p, "/") {
+			subject = rel
+		}
+		if ok, _ := path.Match(p, subject); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// selection is the verdict of walker.selects on a directory child.
+type selection int
+
+const (
+	skip    selection = iota // leave out, and do not descend into it
+	show                     // emit it
+	descend                  // do not emit it, but still walk its contents
+)
+
+// selects applies hidden-file, exclude, .gitignore and include filtering to
+// the child name of a directory, at rel below the listed path.
+func (wk *walker) selects(name, rel string, isDir bool) selection {
+	if wk.opts.HideDotfiles && strings.HasPrefix(name, ".") {
+		return skip
+	}
+	if matchAny(wk.opts.Exclude, name, rel) {
+		return skip
+	}
+	if wk.opts.GitIgnore && (name == ".git" && isDir || wk.ignored(rel, isDir)) {
+		return skip
+	}
+	if len(wk.opts.Include) > 0 && !matchAny(wk.opts.Include, name, rel) {
+		if isDir && wk.opts.Recursive {
+			return descend
+		}
+		return skip
+	}
+	return show
+}
+
+// ignoreFile holds the rules of one .gitignore file.
+type ignoreFile struct {
+	dir   string // slash-separated directory of the file, relative to the listed path
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignored reports whether the .gitignore files on the current descent path
+// exclude rel. As in git, the last matching rule wins and deeper files take
+// precedence.
+func (wk *walker) ignored(rel string, isDir bool) bool {
+	return ignoredBy(wk.ignores, rel, isDir)
+}
+
+// ignoredBy applies the rules of files, outermost first, to rel.
+func ignoredBy(files []*ignoreFile, rel string, isDir bool) bool {
+	ignored := false
+	for _, f := range files {
+		sub := rel
+		if f.dir != "" {
+			sub = strings.TrimPrefix(rel, f.dir+"/")
+		}
+		for _, r := range f.rules {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if r.re.MatchString(sub) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// loadIgnore reads the .gitignore file in dir, if any.
+func loadIgnore(dir, rel string) (*ignoreFile, error) {
+	return loadIgnoreFile(filepath.Join(dir, ".gitignore"), rel)
+}
+
+// loadIgnoreFile reads a file of gitignore patterns that apply in the
+// directory rel, if it exists.
+func loadIgnoreFile(name, rel string) (*ignoreFile, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ig := &ignoreFile{dir: rel}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok := parseIgnoreRule(sc.Text()); ok {
+			ig.rules = append(ig.rules, r)
+		}
+	}
+	return ig, sc.Err()
+}
+
+// parseIgnoreRule compiles one line of a .gitignore file following the
+// pattern format in gitignore(5).
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	var r ignoreRule
+	if strings.HasSuffix(line, "\r") {
+		line = line[:len(line)-1]
+	}
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || line[0] == '#' {
+		return r, false
+	}
+	if line[0] == '!' {
+		r.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' && len(line) > 1 && (line[1] == '!' || line[1] == '#') {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return r, false
+	}
+	// A slash anywhere but at the end anchors the pattern to the directory
+	// of the .gitignore file.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], "**/") && (i == 0 || line[i-1] == '/'):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case line[i:] == "**" && (i == 0 || line[i-1] == '/'):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				break
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(line):
+			i++
+			b.WriteString(regexp.QuoteMeta(line[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return r, false
+	}
+	r.re = re
+	return r, true
+}
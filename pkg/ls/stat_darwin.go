@@ -12,6 +12,7 @@ import (
 
 type extraMeta struct {
 	Inode uint64
+	Dev   uint64
 	Nlink uint64
 	Uid   uint32
 	Gid   uint32
@@ -19,6 +20,14 @@ type extraMeta struct {
 	Group string
 	Atime time.Time
 	Ctime time.Time
+	Btime time.Time
+
+	Blocks  int64
+	Blksize int64
+
+	// RdevMajor and RdevMinor split the device number of a device node.
+	RdevMajor uint32
+	RdevMinor uint32
 }
 
 func getExtra(info os.FileInfo) extraMeta {
@@ -28,12 +37,17 @@ func getExtra(info os.FileInfo) extraMeta {
 		return x
 	}
 	x.Inode = uint64(st.Ino)
+	x.Dev = uint64(uint32(st.Dev))
+	x.RdevMajor, x.RdevMinor = splitDev(uint64(uint32(st.Rdev)))
 	x.Nlink = uint64(st.Nlink)
 	x.Uid = st.Uid
 	x.Gid = st.Gid
 	// atime/ctime
 	x.Atime = time.Unix(st.Atimespec.Sec, st.Atimespec.Nsec).UTC()
 	x.Ctime = time.Unix(st.Ctimespec.Sec, st.Ctimespec.Nsec).UTC()
+	x.Btime = time.Unix(st.Birthtimespec.Sec, st.Birthtimespec.Nsec).UTC()
+	x.Blocks = st.Blocks
+	x.Blksize = int64(st.Blksize)
 	// owner name
 	if u, err := user.LookupId(strconv.FormatUint(uint64(st.Uid), 10)); err == nil && u != nil {
 		x.Owner = u.Username
@@ -44,3 +58,17 @@ func getExtra(info os.FileInfo) extraMeta {
 	}
 	return x
 }
+
+// fileID returns the device and inode numbers identifying info's file.
+func fileID(info os.FileInfo) (dev, ino uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st == nil {
+		return 0, 0, false
+	}
+	return uint64(uint32(st.Dev)), st.Ino, true
+}
+
+// splitDev splits a Darwin dev_t into its 8-bit major and 24-bit minor.
+func splitDev(dev uint64) (major, minor uint32) {
+	return uint32(dev>>24) & 0xff, uint32(dev) & 0xffffff
+}
@@ -0,0 +1,37 @@
+//go:build !linux && !darwin
+
+package ls
+
+import (
+	"os"
+	"time"
+)
+
+type extraMeta struct {
+	Inode uint64
+	Dev   uint64
+	Nlink uint64
+	Uid   uint32
+	Gid   uint32
+	Owner string
+	Group string
+	Atime time.Time
+	Ctime time.Time
+	Btime time.Time
+
+	Blocks  int64
+	Blksize int64
+
+	RdevMajor uint32
+	RdevMinor uint32
+}
+
+func getExtra(info os.FileInfo) extraMeta {
+	return extraMeta{}
+}
+
+// fileID reports no identity on platforms without device and inode numbers,
+// which disables cycle detection and --one-file-system there.
+func fileID(info os.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
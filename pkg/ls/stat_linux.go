@@ -0,0 +1,75 @@
+//go:build linux
+
+package ls
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+type extraMeta struct {
+	Inode uint64
+	Dev   uint64
+	Nlink uint64
+	Uid   uint32
+	Gid   uint32
+	Owner string
+	Group string
+	Atime time.Time
+	Ctime time.Time
+	Btime time.Time
+
+	Blocks  int64
+	Blksize int64
+
+	// RdevMajor and RdevMinor split the device number of a device node.
+	RdevMajor uint32
+	RdevMinor uint32
+}
+
+func getExtra(info os.FileInfo) extraMeta {
+	x := extraMeta{}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st == nil {
+		return x
+	}
+	x.Inode = st.Ino
+	x.Dev = uint64(st.Dev)
+	x.RdevMajor, x.RdevMinor = splitDev(uint64(st.Rdev))
+	x.Nlink = uint64(st.Nlink)
+	x.Uid = st.Uid
+	x.Gid = st.Gid
+	// atime/ctime
+	x.Atime = time.Unix(st.Atim.Unix()).UTC()
+	x.Ctime = time.Unix(st.Ctim.Unix()).UTC()
+	x.Blocks = int64(st.Blocks)
+	x.Blksize = int64(st.Blksize)
+	// owner name
+	if u, err := user.LookupId(strconv.FormatUint(uint64(st.Uid), 10)); err == nil && u != nil {
+		x.Owner = u.Username
+	}
+	// group name
+	if g, err := user.LookupGroupId(strconv.FormatUint(uint64(st.Gid), 10)); err == nil && g != nil {
+		x.Group = g.Name
+	}
+	return x
+}
+
+// fileID returns the device and inode numbers identifying info's file.
+func fileID(info os.FileInfo) (dev, ino uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st == nil {
+		return 0, 0, false
+	}
+	return uint64(st.Dev), st.Ino, true
+}
+
+// splitDev splits a Linux dev_t as glibc's major() and minor() do.
+func splitDev(dev uint64) (major, minor uint32) {
+	major = uint32((dev>>8)&0xfff | (dev>>32)&^0xfff)
+	minor = uint32(dev&0xff | (dev>>12)&^0xff)
+	return major, minor
+}
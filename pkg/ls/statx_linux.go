@@ -0,0 +1,112 @@
+//go:build linux
+
+package ls
+
+import (
+	"io/fs"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// statxT mirrors struct statx from <linux/stat.h>.
+type statxT struct {
+	Mask           uint32
+	Blksize        uint32
+	Attributes     uint64
+	Nlink          uint32
+	Uid            uint32
+	Gid            uint32
+	Mode           uint16
+	_              uint16
+	Ino            uint64
+	Size           uint64
+	Blocks         uint64
+	AttributesMask uint64
+	Atime          statxTimestamp
+	Btime          statxTimestamp
+	Ctime          statxTimestamp
+	Mtime          statxTimestamp
+	RdevMajor      uint32
+	RdevMinor      uint32
+	DevMajor       uint32
+	DevMinor       uint32
+	MntID          uint64
+	_              [13]uint64
+}
+
+type statxTimestamp struct {
+	Sec  int64
+	Nsec uint32
+	_    int32
+}
+
+const (
+	atFDCWD           = -0x64
+	atSymlinkNoFollow = 0x100
+
+	statxBasicStats = 0x7ff
+	statxBtime      = 0x800
+	statxMntID      = 0x1000
+)
+
+// statxAttrs names the STATX_ATTR_* bits reported in Entry.Attributes.
+var statxAttrs = []struct {
+	bit  uint64
+	name string
+}{
+	{0x4, "compressed"},
+	{0x10, "immutable"},
+	{0x20, "append"},
+	{0x40, "nodump"},
+	{0x800, "encrypted"},
+	{0x100000, "verity"},
+	{0x200000, "dax"},
+}
+
+// statxUnsupported is set once the kernel (or a seccomp filter) has
+// rejected statx, so later entries do not retry it.
+var statxUnsupported atomic.Bool
+
+// statx adds the birth time, attributes and mount id that only statx(2)
+// reports. Kernels without statx leave e as it is.
+func statx(e *Entry, path string, nofollow bool) error {
+	if statxUnsupported.Load() {
+		return nil
+	}
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	flags := 0
+	if nofollow {
+		flags = atSymlinkNoFollow
+	}
+	var st statxT
+	dirfd := atFDCWD
+	_, _, errno := syscall.Syscall6(sysStatx, uintptr(dirfd), uintptr(unsafe.Pointer(p)), uintptr(flags),
+		statxBasicStats|statxBtime|statxMntID, uintptr(unsafe.Pointer(&st)), 0)
+	if errno != 0 {
+		if errno == syscall.ENOSYS || errno == syscall.EPERM {
+			statxUnsupported.Store(true)
+			return nil
+		}
+		return &fs.PathError{Op: "statx", Path: path, Err: errno}
+	}
+
+	if st.Mask&statxBtime != 0 {
+		e.Btime, e.BtimeUnixNs = timestamp(time.Unix(st.Btime.Sec, int64(st.Btime.Nsec)))
+	}
+	if st.Mask&statxMntID != 0 {
+		e.MountID = st.MntID
+	}
+	e.Blocks = int64(st.Blocks)
+	e.Blksize = int64(st.Blksize)
+	for _, a := range statxAttrs {
+		if st.AttributesMask&a.bit != 0 && st.Attributes&a.bit != 0 {
+			e.Attributes = append(e.Attributes, a.name)
+		}
+	}
+	return nil
+}
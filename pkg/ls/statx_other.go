@@ -0,0 +1,9 @@
+//go:build !linux
+
+package ls
+
+// statx is a no-op: statx(2) is Linux only. Birth times come from stat on
+// platforms that have them.
+func statx(e *Entry, path string, nofollow bool) error {
+	return nil
+}
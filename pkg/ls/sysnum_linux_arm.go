@@ -0,0 +1,4 @@
+package ls
+
+// sysStatx is the statx(2) system call number on linux/arm.
+const sysStatx = 397
@@ -0,0 +1,159 @@
+package ls
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// ACL is a decoded POSIX access control list.
+type ACL struct {
+	Access  []ACLEntry `json:"access,omitempty"`  // from system.posix_acl_access
+	Default []ACLEntry `json:"default,omitempty"` // from system.posix_acl_default, for directories
+}
+
+// ACLEntry is one entry of a POSIX ACL, as shown by getfacl.
+type ACLEntry struct {
+	Tag    string  `json:"tag"`               // user_obj|user|group_obj|group|mask|other|unknown
+	RawTag *uint16 `json:"raw_tag,omitempty"` // numeric tag, for unknown tags
+	ID     *uint32 `json:"id,omitempty"`      // uid or gid, for user and group entries
+	Name   string  `json:"name,omitempty"`    // user or group name of id
+	Perms  string  `json:"perms"`             // e.g. "rw-"
+}
+
+// Capabilities are the file capabilities decoded from security.capability.
+type Capabilities struct {
+	Effective   bool     `json:"effective"`         // permitted capabilities are raised on exec
+	Permitted   []string `json:"permitted"`         // e.g. ["cap_net_bind_service"]
+	Inheritable []string `json:"inheritable"`       // e.g. ["cap_net_raw"]
+	RootID      *uint32  `json:"root_id,omitempty"` // namespace root uid, for version 3 capabilities
+}
+
+var aclTags = map[uint16]string{
+	0x01: "user_obj",
+	0x02: "user",
+	0x04: "group_obj",
+	0x08: "group",
+	0x10: "mask",
+	0x20: "other",
+}
+
+// decodeACL decodes the posix_acl_xattr format: a version 2 header
+// followed by (tag, perm, id) entries, all little-endian.
+func decodeACL(b []byte) ([]ACLEntry, error) {
+	if len(b) < 4 || binary.LittleEndian.Uint32(b) != 2 || (len(b)-4)%8 != 0 {
+		return nil, errors.New("malformed POSIX ACL")
+	}
+	entries := make([]ACLEntry, 0, (len(b)-4)/8)
+	for b = b[4:]; len(b) > 0; b = b[8:] {
+		tag := binary.LittleEndian.Uint16(b)
+		perm := binary.LittleEndian.Uint16(b[2:])
+		e := ACLEntry{Tag: aclTags[tag], Perms: rwx(perm)}
+		if e.Tag == "" {
+			e.Tag, e.RawTag = "unknown", &tag
+		}
+		id := binary.LittleEndian.Uint32(b[4:])
+		switch e.Tag {
+		case "user":
+			e.ID = &id
+			if u, err := user.LookupId(strconv.FormatUint(uint64(id), 10)); err == nil {
+				e.Name = u.Username
+			}
+		case "group":
+			e.ID = &id
+			if g, err := user.LookupGroupId(strconv.FormatUint(uint64(id), 10)); err == nil {
+				e.Name = g.Name
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func rwx(perm uint16) string {
+	s := []byte("---")
+	if perm&4 != 0 {
+		s[0] = 'r'
+	}
+	if perm&2 != 0 {
+		s[1] = 'w'
+	}
+	if perm&1 != 0 {
+		s[2] = 'x'
+	}
+	return string(s)
+}
+
+// capNames are the Linux capability names indexed by capability number.
+var capNames = []string{
+	"cap_chown", "cap_dac_override", "cap_dac_read_search", "cap_fowner",
+	"cap_fsetid", "cap_kill", "cap_setgid", "cap_setuid",
+	"cap_setpcap", "cap_linux_immutable", "cap_net_bind_service", "cap_net_broadcast",
+	"cap_net_admin", "cap_net_raw", "cap_ipc_lock", "cap_ipc_owner",
+	"cap_sys_module", "cap_sys_rawio", "cap_sys_chroot", "cap_sys_ptrace",
+	"cap_sys_pacct", "cap_sys_admin", "cap_sys_boot", "cap_sys_nice",
+	"cap_sys_resource", "cap_sys_time", "cap_sys_tty_config", "cap_mknod",
+	"cap_lease", "cap_audit_write", "cap_audit_control", "cap_setfcap",
+	"cap_mac_override", "cap_mac_admin", "cap_syslog", "cap_wake_alarm",
+	"cap_block_suspend", "cap_audit_read", "cap_perfmon", "cap_bpf",
+	"cap_checkpoint_restore",
+}
+
+// decodeCapabilities decodes struct vfs_cap_data (or vfs_ns_cap_data for
+// revision 3): a magic word carrying the revision and the effective flag,
+// then permitted/inheritable pairs of 32-bit words, low word first.
+func decodeCapabilities(b []byte) (*Capabilities, error) {
+	if len(b) < 4 {
+		return nil, errors.New("malformed file capabilities")
+	}
+	magic := binary.LittleEndian.Uint32(b)
+	words := 0
+	switch rev := magic & 0xff000000; rev {
+	case 0x01000000:
+		words = 1
+	case 0x02000000, 0x03000000:
+		words = 2
+	default:
+		return nil, fmt.Errorf("unknown file capabilities revision 0x%x", rev>>24)
+	}
+	size := 4 + words*8
+	if magic&0xff000000 == 0x03000000 {
+		size += 4
+	}
+	if len(b) < size {
+		return nil, errors.New("malformed file capabilities")
+	}
+
+	var permitted, inheritable uint64
+	for i := 0; i < words; i++ {
+		permitted |= uint64(binary.LittleEndian.Uint32(b[4+i*8:])) << (32 * i)
+		inheritable |= uint64(binary.LittleEndian.Uint32(b[8+i*8:])) << (32 * i)
+	}
+	c := &Capabilities{
+		Effective:   magic&1 != 0,
+		Permitted:   capList(permitted),
+		Inheritable: capList(inheritable),
+	}
+	if magic&0xff000000 == 0x03000000 {
+		id := binary.LittleEndian.Uint32(b[4+words*8:])
+		c.RootID = &id
+	}
+	return c, nil
+}
+
+func capList(set uint64) []string {
+	names := make([]string, 0)
+	for i := 0; i < 64; i++ {
+		if set&(1<<i) == 0 {
+			continue
+		}
+		if i < len(capNames) {
+			names = append(names, capNames[i])
+		} else {
+			names = append(names, "cap_"+strconv.Itoa(i))
+		}
+	}
+	return names
+}
@@ -0,0 +1,137 @@
+//go:build linux
+
+package ls
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+	"unsafe"
+)
+
+// readXattrs fills in Xattrs, ACL, Capabilities and SELinuxContext of e.
+// Symlinks that were not followed report their own attributes.
+func readXattrs(e *Entry, path string, nofollow bool) error {
+	names, err := listXattr(path, nofollow)
+	if errors.Is(err, syscall.ENOTSUP) {
+		return nil
+	}
+	if err != nil {
+		return &fs.PathError{Op: "listxattr", Path: path, Err: err}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	e.Xattrs = make(map[string]string, len(names))
+	var firstErr error
+	for _, name := range names {
+		val, err := getXattr(path, name, nofollow)
+		if err != nil {
+			// The attribute may have gone away since it was listed.
+			if err != syscall.ENODATA && firstErr == nil {
+				firstErr = &fs.PathError{Op: "getxattr", Path: path, Err: fmt.Errorf("%s: %w", name, err)}
+			}
+			continue
+		}
+		e.Xattrs[name] = base64.StdEncoding.EncodeToString(val)
+
+		var derr error
+		switch name {
+		case "system.posix_acl_access", "system.posix_acl_default":
+			var acl []ACLEntry
+			if acl, derr = decodeACL(val); derr == nil {
+				if e.ACL == nil {
+					e.ACL = &ACL{}
+				}
+				if name == "system.posix_acl_access" {
+					e.ACL.Access = acl
+				} else {
+					e.ACL.Default = acl
+				}
+			}
+		case "security.capability":
+			e.Capabilities, derr = decodeCapabilities(val)
+		case "security.selinux":
+			e.SELinuxContext = string(bytes.TrimRight(val, "\x00"))
+		}
+		if derr != nil && firstErr == nil {
+			firstErr = &fs.PathError{Op: "getxattr", Path: path, Err: fmt.Errorf("%s: %w", name, derr)}
+		}
+	}
+	return firstErr
+}
+
+func listXattr(path string, nofollow bool) ([]string, error) {
+	trap := uintptr(syscall.SYS_LISTXATTR)
+	if nofollow {
+		trap = syscall.SYS_LLISTXATTR
+	}
+	buf, err := xattrCall(trap, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range bytes.Split(buf, []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+func getXattr(path, name string, nofollow bool) ([]byte, error) {
+	trap := uintptr(syscall.SYS_GETXATTR)
+	if nofollow {
+		trap = syscall.SYS_LGETXATTR
+	}
+	attr, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	return xattrCall(trap, path, attr)
+}
+
+// xattrCall runs the (l)listxattr or, given attr, the (l)getxattr syscall,
+// first asking for the value size and retrying if the value grew.
+func xattrCall(trap uintptr, path string, attr *byte) ([]byte, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	call := func(buf []byte) (int, error) {
+		var ptr unsafe.Pointer
+		if len(buf) > 0 {
+			ptr = unsafe.Pointer(&buf[0])
+		}
+		var r uintptr
+		var errno syscall.Errno
+		if attr == nil {
+			r, _, errno = syscall.Syscall(trap, uintptr(unsafe.Pointer(p)), uintptr(ptr), uintptr(len(buf)))
+		} else {
+			r, _, errno = syscall.Syscall6(trap, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(attr)), uintptr(ptr), uintptr(len(buf)), 0, 0)
+		}
+		if errno != 0 {
+			return 0, errno
+		}
+		return int(r), nil
+	}
+	for {
+		n, err := call(nil)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		n, err = call(buf)
+		if err == syscall.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
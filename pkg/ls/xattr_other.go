@@ -0,0 +1,8 @@
+//go:build !linux
+
+package ls
+
+// readXattrs is a no-op: extended attributes are only read on Linux.
+func readXattrs(e *Entry, path string, nofollow bool) error {
+	return nil
+}
@@ -0,0 +1,36 @@
+package ps
+
+import (
+	"path"
+	"strings"
+)
+
+// DefaultEnvRedact are the environment variable name patterns whose values
+// are redacted when Options.EnvRedact is nil.
+var DefaultEnvRedact = []string{
+	"*TOKEN*", "*SECRET*", "*PASSWORD*", "*PASSWD*",
+	"*CREDENTIAL*", "*API_KEY*", "*PRIVATE_KEY*",
+}
+
+// Redacted replaces the values of redacted environment variables.
+const Redacted = "[REDACTED]"
+
+// ValidEnvPattern reports whether pattern is a valid EnvRedact glob.
+func ValidEnvPattern(pattern string) bool {
+	_, err := path.Match(pattern, "")
+	return err == nil
+}
+
+// redactEnv replaces the values of the variables in env whose names match
+// one of patterns, ignoring case.
+func redactEnv(env map[string]string, patterns []string) {
+	for k := range env {
+		name := strings.ToUpper(k)
+		for _, p := range patterns {
+			if ok, _ := path.Match(strings.ToUpper(p), name); ok {
+				env[k] = Redacted
+				break
+			}
+		}
+	}
+}
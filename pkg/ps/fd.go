@@ -0,0 +1,62 @@
+package ps
+
+import "context"
+
+// FD is a file descriptor open in a process.
+type FD struct {
+	Num     int      `json:"fd"`
+	Kind    string   `json:"kind"`              // file|dir|char_device|block_device|fifo|socket|pipe|memfd|eventfd|epoll|signalfd|timerfd|inotify|fanotify|pidfd|io_uring|anon_inode|unknown
+	Path    string   `json:"path"`              // resolved target, or the kernel's description such as "pipe:[4242]"
+	Deleted bool     `json:"deleted,omitempty"` // the file was removed after it was opened
+	Flags   []string `json:"flags,omitempty"`   // open flags, e.g. O_WRONLY, O_APPEND, O_CLOEXEC
+	Offset  *int64   `json:"offset,omitempty"`  // file position
+	Inode   uint64   `json:"inode,omitempty"`
+	Socket  *Socket  `json:"socket,omitempty"`
+}
+
+// Socket is the socket behind a file descriptor, as listed in /proc/net
+// for the network namespace of the process.
+type Socket struct {
+	Protocol      string `json:"protocol"`                 // tcp|tcp6|udp|udp6|udplite|udplite6|raw|raw6|unix|netlink|packet|unknown
+	Type          string `json:"type,omitempty"`           // stream|dgram|seqpacket (unix sockets)
+	LocalAddress  string `json:"local_address,omitempty"`  // "10.0.0.1:443", "[::1]:53", or the path of a unix socket ("@name" if abstract)
+	RemoteAddress string `json:"remote_address,omitempty"` // peer of a connected inet socket
+	State         string `json:"state,omitempty"`          // e.g. LISTEN, ESTABLISHED; CONNECTED for unix sockets
+}
+
+// OpenFile is a file descriptor together with the process holding it, as
+// reported by jout lsof. The process fields are those of Process.
+type OpenFile struct {
+	PID   int    `json:"pid"`
+	PPID  int    `json:"ppid"`
+	UID   uint32 `json:"uid"`
+	GID   uint32 `json:"gid"`
+	User  string `json:"user"`
+	Group string `json:"group"`
+	Comm  string `json:"comm"`
+	FD
+}
+
+// OpenFiles calls fn for every file descriptor open in the processes Each
+// would report, process by process and in descriptor order.
+func OpenFiles(ctx context.Context, opts Options, fn func(*OpenFile) error) error {
+	opts.FDs = true
+	return Each(ctx, opts, func(p *Process) error {
+		for _, fd := range p.FDs {
+			f := &OpenFile{
+				PID:   p.PID,
+				PPID:  p.PPID,
+				UID:   p.UID,
+				GID:   p.GID,
+				User:  p.User,
+				Group: p.Group,
+				Comm:  p.Comm,
+				FD:    fd,
+			}
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,343 @@
+//go:build linux
+
+package ps
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// readFDs lists the file descriptors open in pid from /proc/[pid]/fd and
+// fdinfo, which only the owner of a process and root can read.
+func readFDs(pid int, sockets *socketTables) ([]FD, error) {
+	base := filepath.Join("/proc", strconv.Itoa(pid))
+	entries, err := os.ReadDir(filepath.Join(base, "fd"))
+	if err != nil {
+		return nil, err
+	}
+	fds := make([]FD, 0, len(entries))
+	for _, e := range entries {
+		n, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		link := filepath.Join(base, "fd", e.Name())
+		target, err := os.Readlink(link)
+		if err != nil {
+			continue // closed in the meantime
+		}
+		fd := FD{Num: n, Path: target}
+		classifyFD(&fd, link)
+		readFDInfo(&fd, filepath.Join(base, "fdinfo", e.Name()))
+		if fd.Kind == "socket" {
+			fd.Socket = sockets.lookup(pid, fd.Inode)
+		}
+		fds = append(fds, fd)
+	}
+	slices.SortFunc(fds, func(a, b FD) int { return a.Num - b.Num })
+	return fds, nil
+}
+
+// anonKinds maps the names of anonymous inodes to FD kinds.
+var anonKinds = map[string]string{
+	"eventfd":   "eventfd",
+	"eventpoll": "epoll",
+	"signalfd":  "signalfd",
+	"timerfd":   "timerfd",
+	"inotify":   "inotify",
+	"fanotify":  "fanotify",
+	"pidfd":     "pidfd",
+	"io_uring":  "io_uring",
+}
+
+// classifyFD sets the kind and inode of fd from its link target and, for
+// paths, from the file the link at link refers to.
+func classifyFD(fd *FD, link string) {
+	target := fd.Path
+	switch {
+	case strings.HasPrefix(target, "socket:["):
+		fd.Kind = "socket"
+		fd.Inode = bracketInode(target)
+	case strings.HasPrefix(target, "pipe:["):
+		fd.Kind = "pipe"
+		fd.Inode = bracketInode(target)
+	case strings.HasPrefix(target, "anon_inode:"):
+		name := strings.Trim(strings.TrimPrefix(target, "anon_inode:"), "[]")
+		if fd.Kind = anonKinds[name]; fd.Kind == "" {
+			fd.Kind = "anon_inode"
+		}
+	case strings.HasPrefix(target, "/"):
+		if p, ok := strings.CutSuffix(target, " (deleted)"); ok {
+			fd.Path, fd.Deleted = p, true
+		}
+		fd.Kind = "unknown"
+		fi, err := os.Stat(link)
+		if err != nil {
+			return
+		}
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			fd.Inode = uint64(st.Ino)
+		}
+		m := fi.Mode()
+		switch {
+		case strings.HasPrefix(fd.Path, "/memfd:"):
+			fd.Kind = "memfd"
+		case m.IsRegular():
+			fd.Kind = "file"
+		case m.IsDir():
+			fd.Kind = "dir"
+		case m&os.ModeCharDevice != 0:
+			fd.Kind = "char_device"
+		case m&os.ModeDevice != 0:
+			fd.Kind = "block_device"
+		case m&os.ModeNamedPipe != 0:
+			fd.Kind = "fifo"
+		case m&os.ModeSocket != 0:
+			fd.Kind = "socket"
+		}
+	default:
+		fd.Kind = "unknown" // e.g. namespaces, "net:[4026531840]"
+	}
+}
+
+// bracketInode parses the inode in "socket:[4242]".
+func bracketInode(s string) uint64 {
+	_, s, _ = strings.Cut(s, "[")
+	n, _ := strconv.ParseUint(strings.TrimSuffix(s, "]"), 10, 64)
+	return n
+}
+
+// readFDInfo sets the offset and flags of fd from its fdinfo file.
+func readFDInfo(fd *FD, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, _ := strings.Cut(sc.Text(), ":")
+		v = strings.TrimSpace(v)
+		switch k {
+		case "pos":
+			if pos, err := strconv.ParseInt(v, 10, 64); err == nil {
+				fd.Offset = &pos
+			}
+		case "flags":
+			if flags, err := strconv.ParseUint(v, 8, 32); err == nil {
+				fd.Flags = openFlagNames(int(flags))
+			}
+		}
+	}
+}
+
+// oPath is O_PATH, which package syscall lacks on some architectures.
+const oPath = 0o10000000
+
+// openFlags are the open(2) flags reported besides the access mode, with
+// O_SYNC before the O_DSYNC bit it includes.
+var openFlags = []struct {
+	bit  int
+	name string
+}{
+	{syscall.O_CREAT, "O_CREAT"},
+	{syscall.O_EXCL, "O_EXCL"},
+	{syscall.O_NOCTTY, "O_NOCTTY"},
+	{syscall.O_TRUNC, "O_TRUNC"},
+	{syscall.O_APPEND, "O_APPEND"},
+	{syscall.O_NONBLOCK, "O_NONBLOCK"},
+	{syscall.O_SYNC, "O_SYNC"},
+	{syscall.O_DSYNC, "O_DSYNC"},
+	{syscall.O_ASYNC, "O_ASYNC"},
+	{syscall.O_DIRECT, "O_DIRECT"},
+	{syscall.O_DIRECTORY, "O_DIRECTORY"},
+	{syscall.O_NOFOLLOW, "O_NOFOLLOW"},
+	{syscall.O_NOATIME, "O_NOATIME"},
+	{syscall.O_CLOEXEC, "O_CLOEXEC"},
+	{oPath, "O_PATH"},
+}
+
+// openFlagNames names the access mode and other flags set in flags, as
+// listed in fdinfo. Bits without a name here, such as the
+// architecture-dependent O_LARGEFILE, are left out.
+func openFlagNames(flags int) []string {
+	names := []string{[...]string{"O_RDONLY", "O_WRONLY", "O_RDWR", "O_ACCMODE"}[flags&syscall.O_ACCMODE]}
+	for _, f := range openFlags {
+		if flags&f.bit == f.bit {
+			names = append(names, f.name)
+			flags &^= f.bit
+		}
+	}
+	return names
+}
+
+// socketTables resolves socket inodes through the tables in /proc/net,
+// read once per network namespace.
+type socketTables struct {
+	byNS map[string]map[uint64]*Socket
+}
+
+func newSocketTables() *socketTables {
+	return &socketTables{byNS: map[string]map[uint64]*Socket{}}
+}
+
+// lookup returns the socket with inode in the network namespace of pid.
+func (t *socketTables) lookup(pid int, inode uint64) *Socket {
+	base := filepath.Join("/proc", strconv.Itoa(pid))
+	ns, err := os.Readlink(filepath.Join(base, "ns", "net"))
+	if err != nil {
+		ns = base // unknown: do not share the tables with other processes
+	}
+	tab, ok := t.byNS[ns]
+	if !ok {
+		tab = readSocketTables(filepath.Join(base, "net"))
+		t.byNS[ns] = tab
+	}
+	if s, ok := tab[inode]; ok {
+		return s
+	}
+	return &Socket{Protocol: "unknown"}
+}
+
+// readSocketTables indexes the sockets listed in the /proc/net directory
+// dir by inode. Tables the kernel does not provide are skipped.
+func readSocketTables(dir string) map[uint64]*Socket {
+	tab := map[uint64]*Socket{}
+	for _, proto := range []string{"tcp", "tcp6", "udp", "udp6", "udplite", "udplite6", "raw", "raw6"} {
+		for _, f := range readTable(filepath.Join(dir, proto)) {
+			// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+			if len(f) < 10 {
+				continue
+			}
+			inode, err := strconv.ParseUint(f[9], 10, 64)
+			if err != nil || inode == 0 {
+				continue
+			}
+			s := &Socket{Protocol: proto}
+			if local, ok := parseInetAddr(f[1]); ok {
+				s.LocalAddress = local.String()
+			}
+			if remote, ok := parseInetAddr(f[2]); ok && (remote.Port() != 0 || !remote.Addr().IsUnspecified()) {
+				s.RemoteAddress = remote.String()
+			}
+			switch {
+			case strings.HasPrefix(proto, "tcp"):
+				s.State = tcpStates[f[3]]
+			case f[3] == "01":
+				s.State = "ESTABLISHED"
+			}
+			tab[inode] = s
+		}
+	}
+
+	for _, f := range readTable(filepath.Join(dir, "unix")) {
+		// Num RefCount Protocol Flags Type St Inode Path
+		if len(f) < 7 {
+			continue
+		}
+		inode, err := strconv.ParseUint(f[6], 10, 64)
+		if err != nil {
+			continue
+		}
+		s := &Socket{Protocol: "unix", Type: unixTypes[f[4]], State: unixStates[f[5]]}
+		if flags, _ := strconv.ParseUint(f[3], 16, 32); flags&0x10000 != 0 { // __SO_ACCEPTCON
+			s.State = "LISTEN"
+		}
+		if len(f) > 7 {
+			s.LocalAddress = strings.Join(f[7:], " ")
+		}
+		tab[inode] = s
+	}
+
+	for _, proto := range []string{"netlink", "packet"} {
+		for _, f := range readTable(filepath.Join(dir, proto)) {
+			// The inode is the last column.
+			if inode, err := strconv.ParseUint(f[len(f)-1], 10, 64); err == nil {
+				tab[inode] = &Socket{Protocol: proto}
+			}
+		}
+	}
+	return tab
+}
+
+// readTable returns the whitespace-separated fields of each line of a
+// /proc/net table, without the header line.
+func readTable(path string) [][]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var rows [][]string
+	sc := bufio.NewScanner(f)
+	sc.Scan() // header
+	for sc.Scan() {
+		if fields := strings.Fields(sc.Text()); len(fields) > 0 {
+			rows = append(rows, fields)
+		}
+	}
+	return rows
+}
+
+// parseInetAddr decodes an address such as "0100007F:0050". The kernel
+// prints each 32-bit word of the address as a host-order integer, and the
+// port in hex.
+func parseInetAddr(s string) (netip.AddrPort, bool) {
+	hexAddr, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return netip.AddrPort{}, false
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return netip.AddrPort{}, false
+	}
+	raw, err := hex.DecodeString(hexAddr)
+	if err != nil || len(raw)%4 != 0 {
+		return netip.AddrPort{}, false
+	}
+	for i := 0; i < len(raw); i += 4 {
+		binary.NativeEndian.PutUint32(raw[i:], binary.BigEndian.Uint32(raw[i:]))
+	}
+	addr, ok := netip.AddrFromSlice(raw)
+	if !ok {
+		return netip.AddrPort{}, false
+	}
+	return netip.AddrPortFrom(addr, uint16(port)), true
+}
+
+// tcpStates names the states of include/net/tcp_states.h.
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+	"0C": "NEW_SYN_RECV",
+}
+
+var unixTypes = map[string]string{
+	"0001": "stream",
+	"0002": "dgram",
+	"0005": "seqpacket",
+}
+
+var unixStates = map[string]string{
+	"01": "UNCONNECTED",
+	"02": "CONNECTING",
+	"03": "CONNECTED",
+	"04": "DISCONNECTING",
+}
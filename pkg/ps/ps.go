@@ -0,0 +1,196 @@
+// Package ps reads the process table with the details reported by
+// `jout ps`.
+package ps
+
+import (
+	"context"
+	"time"
+)
+
+// Process is a single process as reported by jout ps.
+type Process struct {
+	// Identity
+	PID   int    `json:"pid"`
+	PPID  int    `json:"ppid"`
+	UID   uint32 `json:"uid"`
+	GID   uint32 `json:"gid"`
+	User  string `json:"user"`
+	Group string `json:"group"`
+
+	// State / terminal
+	State   string   `json:"state"`          // R|S|D|T|Z|I (running,sleeping,io wait,stopped,zombie,idle)
+	TTY     string   `json:"tty"`            // "pts/0", "tty1"; null if none (kept without omitempty to emit null)
+	Comm    string   `json:"comm"`           // short name, e.g. "sshd"
+	Command string   `json:"command"`        // argv joined with spaces; may be empty if restricted
+	Argv    []string `json:"argv,omitempty"` // arguments as passed to the program, unambiguous unlike command
+
+	// Paths
+	Exe string `json:"exe,omitempty"` // resolved binary path
+	Cwd string `json:"cwd,omitempty"` // working directory
+
+	// Environment (with Options.Env, Linux); absent if empty or if it
+	// cannot be read, which EnvError tells apart
+	Env      map[string]string `json:"env,omitempty"`
+	EnvError string            `json:"env_error,omitempty"` // why env could not be read, e.g. "permission denied"
+
+	// Open file descriptors (with Options.FDs, Linux)
+	FDs []FD `json:"fds,omitempty"`
+
+	// CPU & memory (cumulative since start)
+	CPUUserSeconds   float64  `json:"cpu_user_seconds"`
+	CPUSystemSeconds float64  `json:"cpu_system_seconds"`
+	MemRSSBytes      int64    `json:"mem_rss_bytes"`
+	MemVMSBytes      int64    `json:"mem_vms_bytes"`
+	MemSwapBytes     int64    `json:"mem_swap_bytes,omitempty"` // if available
+	MajorFaults      *uint64  `json:"major_faults,omitempty"`   // page faults that needed I/O (Linux)
+	MemDetail        *ProcMem `json:"mem_detail,omitempty"`     // with Options.MemDetail (Linux)
+
+	// Rates over the sampling interval (with Options.Sample); absent for
+	// processes that started during it
+	CPUPercent        *float64 `json:"cpu_percent,omitempty"` // user+system time over wall time; above 100 when using several CPUs
+	MajorFaultsPerSec *float64 `json:"major_faults_per_sec,omitempty"`
+
+	Threads  *int `json:"threads,omitempty"`
+	Nice     *int `json:"nice,omitempty"`
+	Priority *int `json:"priority,omitempty"`
+
+	// Start/elapsed
+	StartTime       string `json:"start_time"`         // RFC3339 UTC
+	StartTimeUnixNs int64  `json:"start_time_unix_ns"` // monotonic-friendly
+	ElapsedSeconds  *int64 `json:"elapsed_seconds,omitempty"`
+
+	// Containers / cgroups / namespaces (Linux)
+	Cgroup      *string         `json:"cgroup,omitempty"`  // primary/legacy cgroup path
+	Cgroups     *[]string       `json:"cgroups,omitempty"` // all cgroup paths (v1/v2)
+	NS          *ProcNamespaces `json:"namespaces,omitempty"`
+	ContainerID *string         `json:"container_id,omitempty"` // docker/cri
+
+	// I/O stats (Linux)
+	IO *ProcIO `json:"io,omitempty"`
+
+	// Security labels (Linux, optional)
+	SELinuxLabel *string `json:"selinux_label,omitempty"`
+
+	// errs and detailErrs are the errors reading the optional details and
+	// the other details of the process, which are left out; they are
+	// reported to Options.OnError and OnDetailError if the process is kept
+	errs       []error
+	detailErrs []error
+}
+
+// ProcIO holds cumulative storage I/O counters.
+type ProcIO struct {
+	ReadBytes  uint64 `json:"read_bytes"`
+	WriteBytes uint64 `json:"write_bytes"`
+
+	// Rates over the sampling interval (with Options.Sample)
+	ReadBytesPerSec  *float64 `json:"read_bytes_per_sec,omitempty"`
+	WriteBytesPerSec *float64 `json:"write_bytes_per_sec,omitempty"`
+}
+
+// ProcMem breaks down the memory of a process by how it is shared, from
+// /proc/[pid]/smaps_rollup. PSS divides each shared page among the
+// processes mapping it, so it adds up across processes where RSS does not.
+type ProcMem struct {
+	PSSBytes     int64 `json:"pss_bytes"`      // proportional set size
+	USSBytes     int64 `json:"uss_bytes"`      // unique set size: private clean and dirty pages
+	SharedBytes  int64 `json:"shared_bytes"`   // resident pages also mapped by other processes
+	AnonBytes    int64 `json:"anon_bytes"`     // resident anonymous memory
+	FileBytes    int64 `json:"file_bytes"`     // resident file-backed memory, including shared memory
+	SwapPSSBytes int64 `json:"swap_pss_bytes"` // proportional share of swapped out pages
+}
+
+// ProcNamespaces holds namespace identifiers, e.g. "net:[4026531840]".
+type ProcNamespaces struct {
+	Mnt    string `json:"mnt,omitempty"`
+	PID    string `json:"pid,omitempty"`
+	Net    string `json:"net,omitempty"`
+	UTS    string `json:"uts,omitempty"`
+	IPC    string `json:"ipc,omitempty"`
+	User   string `json:"user,omitempty"`
+	Cgroup string `json:"cgroup,omitempty"`
+}
+
+// Options configure Processes and Each.
+type Options struct {
+	// User keeps only processes owned by this user name.
+	User string
+
+	// Sample, if positive, reads the process table twice, Sample apart,
+	// and reports the second reading with CPUPercent and the other rates
+	// set for processes present in both. A PID reused in between counts as
+	// a new process.
+	Sample time.Duration
+
+	// MemDetail adds MemDetail, which is costlier to read (Linux).
+	MemDetail bool
+
+	// Env adds the environment of each process (Linux), with the values
+	// of variables whose names match one of EnvRedact, ignoring case,
+	// replaced by Redacted. A nil EnvRedact means DefaultEnvRedact.
+	Env       bool
+	EnvRedact []string
+
+	// FDs adds the open file descriptors of each process (Linux).
+	FDs bool
+
+	// OnError is called for processes that exist but cannot be read, and
+	// for the MemDetail, Env or FDs of a kept process that cannot be read.
+	// Processes that exit while being read are skipped silently.
+	OnError func(pid int, err error)
+
+	// OnDetailError is called for other details of a kept process that
+	// cannot be read and are left out, such as the executable of another
+	// user's process. Unprivileged callers hit these for most processes.
+	OnDetailError func(pid int, err error)
+}
+
+// Processes returns a snapshot of the running processes.
+func Processes(ctx context.Context, opts Options) ([]*Process, error) {
+	procs := make([]*Process, 0)
+	err := Each(ctx, opts, func(p *Process) error {
+		procs = append(procs, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return procs, nil
+}
+
+// Each calls fn for every running process as soon as it has been read,
+// stopping at the first error fn returns.
+func Each(ctx context.Context, opts Options, fn func(*Process) error) error {
+	report := opts.OnError
+	if report == nil {
+		report = func(int, error) {}
+	}
+	keep := func(p *Process) bool {
+		return opts.User == "" || p.User == opts.User
+	}
+	if opts.Sample > 0 {
+		return eachSampled(ctx, opts, keep, fn, report)
+	}
+	return collectProcesses(ctx, opts, func(p *Process) error {
+		if !keep(p) {
+			return nil
+		}
+		p.reportErrs(opts)
+		return fn(p)
+	}, report)
+}
+
+// reportErrs passes the errors reading details of p to the callbacks of
+// opts.
+func (p *Process) reportErrs(opts Options) {
+	for _, err := range p.errs {
+		if opts.OnError != nil {
+			opts.OnError(p.PID, err)
+		}
+	}
+	for _, err := range p.detailErrs {
+		if opts.OnDetailError != nil {
+			opts.OnDetailError(p.PID, err)
+		}
+	}
+}
@@ -5,31 +5,38 @@ package ps
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/binary"
 	"os/exec"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+	"unsafe"
 )
 
-func collectProcesses() ([]*Process, error) {
+// startTimeSlack is how far the start times of one process may differ
+// between readings, as they are derived from the whole seconds of etime.
+const startTimeSlack = 2 * time.Second
+
+func collectProcesses(ctx context.Context, opts Options, emit func(*Process) error, report func(pid int, err error)) error {
 	columns := []string{
 		"pid=", "ppid=", "uid=", "rgid=", "user=", "rgroup=",
 		"state=", "tt=", "comm=", "time=",
 		"rss=", "vsz=", "nice=", "pri=", "etime=", "command=",
 	}
 	spec := strings.Join(columns, ",")
-	out, err := exec.Command("ps", "axo", spec).Output()
+	out, err := exec.CommandContext(ctx, "ps", "axo", spec).Output()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	now := time.Now()
+	argBuf := make([]byte, argMax())
 
 	scanner := bufio.NewScanner(bytes.NewReader(out))
 	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
 
-	var procs []*Process
-
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
@@ -55,7 +62,7 @@ func collectProcesses() ([]*Process, error) {
 		}
 		comm := fields[8]
 
-		cputimeSec := parseElapsedToSeconds(fields[9])
+		cputimeSec := parseCPUTime(fields[9])
 
 		rssKB, _ := strconv.ParseInt(fields[10], 10, 64)
 		vszKB, _ := strconv.ParseInt(fields[11], 10, 64)
@@ -80,8 +87,9 @@ func collectProcesses() ([]*Process, error) {
 			TTY:     tty,
 			Comm:    comm,
 			Command: command,
+			Argv:    readArgv(pid, argBuf),
 
-			CPUUserSeconds:   float64(cputimeSec), // Darwin ps exposes total CPU in `time`; we record it here
+			CPUUserSeconds:   cputimeSec, // Darwin ps exposes total CPU in `time`; we record it here
 			CPUSystemSeconds: 0,
 			MemRSSBytes:      rssKB * 1024,
 			MemVMSBytes:      vszKB * 1024,
@@ -94,13 +102,65 @@ func collectProcesses() ([]*Process, error) {
 			ElapsedSeconds:  &elapsed,
 		}
 
-		procs = append(procs, p)
+		if err := emit(p); err != nil {
+			return err
+		}
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	return scanner.Err()
+}
+
+// argMax is the size of the buffer readArgv needs.
+func argMax() int {
+	size, err := syscall.SysctlUint32("kern.argmax")
+	if err != nil || size == 0 {
+		return 1 << 18
+	}
+	return int(size)
+}
+
+// readArgv returns the argument vector of pid from the kernel, since ps
+// joins it with spaces, using buf of argMax bytes. It is nil for processes
+// of other users unless running as root.
+func readArgv(pid int, buf []byte) []string {
+	const (
+		ctlKern       = 1
+		kernProcArgs2 = 49
+	)
+	n := uintptr(len(buf))
+	mib := [3]int32{ctlKern, kernProcArgs2, int32(pid)}
+	_, _, errno := syscall.Syscall6(syscall.SYS___SYSCTL,
+		uintptr(unsafe.Pointer(&mib[0])), uintptr(len(mib)),
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&n)), 0, 0)
+	if errno != 0 {
+		return nil
 	}
+	return parseProcArgs(buf[:n])
+}
 
-	return procs, nil
+// parseProcArgs decodes a KERN_PROCARGS2 buffer: argc as a native int32,
+// the executable path, NUL padding, then argc NUL-terminated arguments
+// followed by the environment.
+func parseProcArgs(b []byte) []string {
+	if len(b) < 4 {
+		return nil
+	}
+	argc := int(binary.LittleEndian.Uint32(b))
+	b = b[4:]
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return nil
+	}
+	b = bytes.TrimLeft(b[i:], "\x00")
+	argv := make([]string, 0, argc)
+	for len(argv) < argc && len(b) > 0 {
+		i := bytes.IndexByte(b, 0)
+		if i < 0 {
+			i = len(b)
+		}
+		argv = append(argv, string(b[:i]))
+		b = b[min(i+1, len(b)):]
+	}
+	return argv
 }
 
 // naiveShellSplit splits on spaces while keeping simple quoted segments together.
@@ -149,6 +209,18 @@ func firstStateRune(state string) string {
 	}
 }
 
+// parseCPUTime parses the ps(1) time format, as parseElapsedToSeconds but
+// with hundredths of a second, e.g. "1:02.53".
+func parseCPUTime(s string) float64 {
+	s = strings.TrimSpace(s)
+	var frac float64
+	if i := strings.LastIndexByte(s, '.'); i >= 0 {
+		frac, _ = strconv.ParseFloat("0"+s[i:], 64)
+		s = s[:i]
+	}
+	return float64(parseElapsedToSeconds(s)) + frac
+}
+
 // parseElapsedToSeconds parses ps(1) etime/utime/stime formats on macOS:
 //
 //	MM:SS
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !windows
+
+package ps
+
+import (
+	"context"
+	"errors"
+)
+
+const startTimeSlack = 0
+
+func collectProcesses(ctx context.Context, opts Options, emit func(*Process) error, report func(pid int, err error)) error {
+	return errors.New("ps is not supported on this platform")
+}
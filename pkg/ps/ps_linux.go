@@ -4,9 +4,11 @@ package ps
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"os/user"
@@ -14,22 +16,34 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
-// collectProcesses gathers processes using the Linux /proc filesystem.
-func collectProcesses() ([]*Process, error) {
+// startTimeSlack is how far the start times of one process may differ
+// between readings: none, as they derive from the same clock ticks.
+const startTimeSlack = 0
+
+// collectProcesses gathers processes using the Linux /proc filesystem and
+// passes each one to emit as soon as it has been read. Processes that cannot
+// be read are passed to report, except those that exited in the meantime.
+// Details of a process that cannot be read are left out and their errors
+// kept in Process.errs and detailErrs.
+func collectProcesses(ctx context.Context, opts Options, emit func(*Process) error, report func(pid int, err error)) error {
 	entries, err := os.ReadDir("/proc")
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	hz := clockTicks()
+	sockets := newSocketTables()
+	hz := clockTicks(ctx)
 	btime, _ := bootTime()
 	now := time.Now()
 
-	procs := make([]*Process, 0, len(entries))
 	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if !e.IsDir() {
 			continue
 		}
@@ -40,12 +54,60 @@ func collectProcesses() ([]*Process, error) {
 
 		p, err := readOneProcess(pid, hz, btime, now)
 		if err != nil {
-			// Permissions or short-lived processes—skip quietly
+			// Short-lived processes vanish between readdir and read—skip quietly
+			if !vanished(err) {
+				report(pid, err)
+			}
 			continue
 		}
-		procs = append(procs, p)
+		if opts.MemDetail {
+			p.MemDetail, err = readSmapsRollup(filepath.Join("/proc", e.Name(), "smaps_rollup"))
+			p.addErr(err)
+		}
+		if opts.Env {
+			p.Env, err = readEnviron(filepath.Join("/proc", e.Name(), "environ"))
+			if err != nil && !vanished(err) {
+				p.EnvError = errorReason(err)
+			}
+			p.addErr(err)
+			redact := opts.EnvRedact
+			if redact == nil {
+				redact = DefaultEnvRedact
+			}
+			redactEnv(p.Env, redact)
+		}
+		if opts.FDs {
+			p.FDs, err = readFDs(pid, sockets)
+			p.addErr(err)
+		}
+		if err := emit(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vanished reports whether err comes from reading a process that has
+// exited.
+func vanished(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ESRCH)
+}
+
+// errorReason describes err without the operation and path, e.g.
+// "permission denied".
+func errorReason(err error) string {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return pe.Err.Error()
+	}
+	return err.Error()
+}
+
+// addErr keeps err, unless it is nil or the process has exited.
+func (p *Process) addErr(err error) {
+	if err != nil && !vanished(err) {
+		p.errs = append(p.errs, err)
 	}
-	return procs, nil
 }
 
 func readOneProcess(pid int, hz int64, btime int64, now time.Time) (*Process, error) {
@@ -56,7 +118,9 @@ func readOneProcess(pid int, hz int64, btime int64, now time.Time) (*Process, er
 		return nil, err
 	}
 
-	status, _ := readStatusMap(filepath.Join(base, "status"))
+	var errs []error
+	status, err := readStatusMap(filepath.Join(base, "status"))
+	errs = append(errs, err)
 
 	ppid := st.ppid
 	uid := parseFirstUint(status["Uid"]) // real uid
@@ -66,7 +130,7 @@ func readOneProcess(pid int, hz int64, btime int64, now time.Time) (*Process, er
 	gname := lookupGroupName(gid)
 
 	// Command & comm
-	cmd := readCmdline(filepath.Join(base, "cmdline"))
+	cmd, argv := readCmdline(filepath.Join(base, "cmdline"))
 	if cmd == "" {
 		// Restricted or zombie; fall back to comm only
 		cmd = ""
@@ -74,8 +138,10 @@ func readOneProcess(pid int, hz int64, btime int64, now time.Time) (*Process, er
 	comm := st.comm
 
 	// Paths
-	exe := readLink(filepath.Join(base, "exe"))
-	cwd := readLink(filepath.Join(base, "cwd"))
+	exe, err := readLink(filepath.Join(base, "exe"))
+	errs = append(errs, err)
+	cwd, err := readLink(filepath.Join(base, "cwd"))
+	errs = append(errs, err)
 
 	// CPU
 	cpuUser := float64(st.utime) / float64(hz)
@@ -94,8 +160,8 @@ func readOneProcess(pid int, hz int64, btime int64, now time.Time) (*Process, er
 	// Start time / elapsed
 	var start time.Time
 	if btime > 0 && hz > 0 {
-		startSec := btime + int64(st.starttime)/hz
-		start = time.Unix(startSec, 0)
+		ticks := int64(st.starttime)
+		start = time.Unix(btime+ticks/hz, ticks%hz*int64(time.Second)/hz)
 	} else {
 		start = now // best effort
 	}
@@ -115,7 +181,8 @@ func readOneProcess(pid int, hz int64, btime int64, now time.Time) (*Process, er
 	ns := readNamespaces(filepath.Join(base, "ns"))
 
 	// IO stats
-	ioStats := readIO(filepath.Join(base, "io"))
+	ioStats, err := readIO(filepath.Join(base, "io"))
+	errs = append(errs, err)
 
 	// SELinux
 	seLinux := readSELinuxLabel(filepath.Join(base, "attr", "current"))
@@ -132,6 +199,7 @@ func readOneProcess(pid int, hz int64, btime int64, now time.Time) (*Process, er
 		TTY:     tty,
 		Comm:    comm,
 		Command: cmd,
+		Argv:    argv,
 
 		Exe: exe,
 		Cwd: cwd,
@@ -141,6 +209,7 @@ func readOneProcess(pid int, hz int64, btime int64, now time.Time) (*Process, er
 		MemRSSBytes:      memRSS,
 		MemVMSBytes:      memVMS,
 		MemSwapBytes:     memSwap,
+		MajorFaults:      &st.majflt,
 
 		Threads:  &threads,
 		Nice:     &nice,
@@ -157,7 +226,11 @@ func readOneProcess(pid int, hz int64, btime int64, now time.Time) (*Process, er
 		IO:           ioStats,
 		SELinuxLabel: seLinux,
 	}
-
+	for _, err := range errs {
+		if err != nil && !vanished(err) {
+			p.detailErrs = append(p.detailErrs, err)
+		}
+	}
 	return p, nil
 }
 
@@ -167,6 +240,7 @@ type procStat struct {
 	ppid       int
 	state      string
 	comm       string
+	majflt     uint64
 	utime      uint64
 	stime      uint64
 	starttime  uint64
@@ -194,23 +268,24 @@ func readProcStat(base string) (*procStat, error) {
 	if len(fields) < 20 { // we need at least up to nice/threads/starttime
 		return nil, errors.New("short /proc/[pid]/stat")
 	}
-	// Field indexes relative to after ')':
-	// 1 state, 2 ppid, 6 tty_nr, 13 utime, 14 stime, 17 priority, 18 nice, 19 num_threads, 21 starttime
-	state := fields[0]
-	ppid, _ := strconv.Atoi(fields[1])
-	// tty_nr is field 6
-	ttyNr, _ := strconv.ParseInt(fields[5], 10, 64)
-	utime, _ := strconv.ParseUint(fields[13-1], 10, 64)
-	stime, _ := strconv.ParseUint(fields[14-1], 10, 64)
-	priority, _ := strconv.ParseInt(fields[17-1], 10, 64)
-	nice, _ := strconv.ParseInt(fields[18-1], 10, 64)
-	numThreads, _ := strconv.ParseInt(fields[19-1], 10, 64)
-	starttime, _ := strconv.ParseUint(fields[21-1], 10, 64)
+	// Fields are numbered as in proc(5), where state is field 3.
+	field := func(n int) string { return fields[n-3] }
+	state := field(3)
+	ppid, _ := strconv.Atoi(field(4))
+	ttyNr, _ := strconv.ParseInt(field(7), 10, 64)
+	majflt, _ := strconv.ParseUint(field(12), 10, 64)
+	utime, _ := strconv.ParseUint(field(14), 10, 64)
+	stime, _ := strconv.ParseUint(field(15), 10, 64)
+	priority, _ := strconv.ParseInt(field(18), 10, 64)
+	nice, _ := strconv.ParseInt(field(19), 10, 64)
+	numThreads, _ := strconv.ParseInt(field(20), 10, 64)
+	starttime, _ := strconv.ParseUint(field(22), 10, 64)
 
 	return &procStat{
 		ppid:       ppid,
 		state:      state,
 		comm:       comm,
+		majflt:     majflt,
 		utime:      utime,
 		stime:      stime,
 		starttime:  starttime,
@@ -283,21 +358,76 @@ func lookupGroupName(gid uint32) string {
 	return strconv.Itoa(int(gid))
 }
 
-func readCmdline(path string) string {
+// readCmdline returns the NUL-terminated arguments in /proc/[pid]/cmdline,
+// joined with spaces and as a slice. Processes that rewrite their title
+// leave the rest of the argument area as NULs, so every trailing NUL is
+// padding: empty arguments at the end cannot be told apart from it and are
+// dropped.
+func readCmdline(path string) (string, []string) {
 	b, err := os.ReadFile(path)
 	if err != nil || len(b) == 0 {
-		return ""
+		return "", nil
 	}
 	parts := strings.Split(strings.TrimRight(string(b), "\x00"), "\x00")
-	return strings.Join(parts, " ")
+	return strings.Join(parts, " "), parts
 }
 
-func readLink(path string) string {
-	p, err := os.Readlink(path)
+// readSmapsRollup reads the memory breakdown of a process. It is nil for
+// kernel threads, which have no memory of their own.
+func readSmapsRollup(path string) (*ProcMem, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return ""
+		return nil, err
 	}
-	return p
+	defer f.Close()
+
+	kb := map[string]int64{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), ":")
+		if ok && strings.HasSuffix(v, " kB") {
+			kb[k] = parseKB(v)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(kb) == 0 {
+		return nil, nil
+	}
+	return &ProcMem{
+		PSSBytes:     kb["Pss"] * 1024,
+		USSBytes:     (kb["Private_Clean"] + kb["Private_Dirty"]) * 1024,
+		SharedBytes:  (kb["Shared_Clean"] + kb["Shared_Dirty"]) * 1024,
+		AnonBytes:    kb["Anonymous"] * 1024,
+		FileBytes:    (kb["Rss"] - kb["Anonymous"]) * 1024,
+		SwapPSSBytes: kb["SwapPss"] * 1024,
+	}, nil
+}
+
+// readEnviron reads the NUL-separated NAME=value pairs of
+// /proc/[pid]/environ, which only the owner of a process and root can read.
+// As with getenv, the first of repeated names wins.
+func readEnviron(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	env := map[string]string{}
+	for _, kv := range strings.Split(string(b), "\x00") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			continue
+		}
+		if _, dup := env[k]; !dup {
+			env[k] = v
+		}
+	}
+	return env, nil
+}
+
+func readLink(path string) (string, error) {
+	return os.Readlink(path)
 }
 
 func normalizeState(s string) string {
@@ -316,7 +446,7 @@ func normalizeState(s string) string {
 
 func deriveTTY(base string, ttyNr int64) string {
 	// Best-effort: follow fd/0. If it's a terminal, it usually points to /dev/pts/N or /dev/ttyN
-	if link := readLink(filepath.Join(base, "fd", "0")); link != "" {
+	if link, _ := readLink(filepath.Join(base, "fd", "0")); link != "" {
 		if strings.HasPrefix(link, "/dev/") {
 			// Normalize like Darwin examples: "pts/0" or "tty1"
 			return strings.TrimPrefix(link, "/dev/")
@@ -326,10 +456,10 @@ func deriveTTY(base string, ttyNr int64) string {
 	return ""
 }
 
-func readIO(path string) *ProcIO {
+func readIO(path string) (*ProcIO, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	defer f.Close()
 
@@ -343,15 +473,16 @@ func readIO(path string) *ProcIO {
 			fmt.Sscanf(line, "write_bytes: %d", &w)
 		}
 	}
-	return &ProcIO{ReadBytes: r, WriteBytes: w}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return &ProcIO{ReadBytes: r, WriteBytes: w}, nil
 }
 
 func readNamespaces(nsDir string) *ProcNamespaces {
 	read := func(name string) string {
-		if p := readLink(filepath.Join(nsDir, name)); p != "" {
-			return p
-		}
-		return ""
+		p, _ := readLink(filepath.Join(nsDir, name))
+		return p
 	}
 	mnt := read("mnt")
 	pid := read("pid")
@@ -456,9 +587,9 @@ func bootTime() (int64, error) {
 	return 0, io.EOF
 }
 
-func clockTicks() int64 {
+func clockTicks(ctx context.Context) int64 {
 	// Prefer a portable query via `getconf` (available on most Linux distros).
-	if out, err := exec.Command("getconf", "CLK_TCK").Output(); err == nil {
+	if out, err := exec.CommandContext(ctx, "getconf", "CLK_TCK").Output(); err == nil {
 		s := strings.TrimSpace(string(out))
 		if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
 			return n
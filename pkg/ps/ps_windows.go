@@ -5,6 +5,7 @@ package ps
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"os/exec"
@@ -13,15 +14,19 @@ import (
 	"time"
 )
 
+// startTimeSlack is how far the start times of one process may differ
+// between readings: none, as CreationDate is fixed.
+const startTimeSlack = 0
+
 // collectProcesses on Windows uses PowerShell CIM (Win32_Process) to retrieve
 // rich per-process information in one pass. It avoids fragile remote PEB
 // parsing and works on stock Windows.
-func collectProcesses() ([]*Process, error) {
+func collectProcesses(ctx context.Context, opts Options, emit func(*Process) error, report func(pid int, err error)) error {
 	script := psScript()
-	out, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-Command", script).Output()
+	out, err := exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-Command", script).Output()
 	if err != nil {
 		// Surface a friendlier error when PowerShell is unavailable or blocked
-		return nil, errors.New("failed to query processes via PowerShell CIM; ensure PowerShell is available and ExecutionPolicy allows running inline commands")
+		return errors.New("failed to query processes via PowerShell CIM; ensure PowerShell is available and ExecutionPolicy allows running inline commands")
 	}
 
 	dec := json.NewDecoder(bytes.NewReader(out))
@@ -35,13 +40,12 @@ func collectProcesses() ([]*Process, error) {
 		dec2 := json.NewDecoder(bytes.NewReader(sanitized))
 		dec2.UseNumber()
 		if err2 := dec2.Decode(&raw); err2 != nil {
-			return nil, err
+			return err
 		}
 	}
 
 	rows := toSliceOfMaps(raw)
 	now := time.Now()
-	procs := make([]*Process, 0, len(rows))
 
 	for _, m := range rows {
 		pid := int(getInt64(m, "ProcessId"))
@@ -52,6 +56,7 @@ func collectProcesses() ([]*Process, error) {
 		ppid := int(getInt64(m, "ParentProcessId"))
 		name := getString(m, "Name")
 		cmd := getString(m, "CommandLine")
+		argv := splitCommandLine(cmd)
 		exe := getString(m, "ExecutablePath")
 
 		// Times
@@ -104,6 +109,7 @@ func collectProcesses() ([]*Process, error) {
 			TTY:     "", // No TTY concept per process like Unix; leave empty
 			Comm:    name,
 			Command: cmd,
+			Argv:    argv,
 
 			Exe: exe,
 			Cwd: "", // expensive to query on Windows; omit
@@ -125,10 +131,12 @@ func collectProcesses() ([]*Process, error) {
 			IO: io,
 		}
 
-		procs = append(procs, p)
+		if err := emit(p); err != nil {
+			return err
+		}
 	}
 
-	return procs, nil
+	return nil
 }
 
 func psScript() string {
@@ -237,6 +245,82 @@ func getUint64(m map[string]any, k string) uint64 {
 	return 0
 }
 
+// splitCommandLine splits a command line into arguments the way
+// CommandLineToArgvW does, which is how most programs receive their argv.
+// The program name ends at the first space or tab outside double quotes and
+// takes backslashes literally. In the other arguments, 2n backslashes before
+// a double quote stand for n backslashes and the quote toggles quoting,
+// 2n+1 stand for n backslashes and a literal quote, and "" inside quotes is
+// a literal quote that also ends quoting.
+func splitCommandLine(s string) []string {
+	s = strings.TrimLeft(s, " \t")
+	if s == "" {
+		return nil
+	}
+	var args []string
+	var b strings.Builder
+	quoted := false
+	i := 0
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			quoted = !quoted
+			continue
+		}
+		if (c == ' ' || c == '\t') && !quoted {
+			break
+		}
+		b.WriteByte(c)
+	}
+	args = append(args, b.String())
+
+	for i < len(s) {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		if i == len(s) {
+			break
+		}
+		b.Reset()
+		quoted = false
+	arg:
+		for ; i < len(s); i++ {
+			c := s[i]
+			switch {
+			case (c == ' ' || c == '\t') && !quoted:
+				break arg
+			case c == '\\':
+				n := 1
+				for i+n < len(s) && s[i+n] == '\\' {
+					n++
+				}
+				if i+n < len(s) && s[i+n] == '"' {
+					b.WriteString(strings.Repeat(`\`, n/2))
+					if n%2 == 1 {
+						b.WriteByte('"')
+						i += n
+					} else {
+						i += n - 1 // the quote is handled next
+					}
+				} else {
+					b.WriteString(s[i : i+n])
+					i += n - 1
+				}
+			case c == '"':
+				if quoted && i+1 < len(s) && s[i+1] == '"' {
+					b.WriteByte('"')
+					i++
+				}
+				quoted = !quoted
+			default:
+				b.WriteByte(c)
+			}
+		}
+		args = append(args, b.String())
+	}
+	return args
+}
+
 func intPtr(v int) *int       { return &v }
 func int64Ptr(v int64) *int64 { return &v }
 
@@ -0,0 +1,86 @@
+package ps
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// reading is a process as read at some time.
+type reading struct {
+	p  *Process
+	at time.Time
+}
+
+// eachSampled reads the process table twice, opts.Sample apart, and
+// passes the processes kept from the second reading to fn, with rates over
+// the interval set on those also present in the first. Read errors are only
+// reported for the second reading.
+func eachSampled(ctx context.Context, opts Options, keep func(*Process) bool, fn func(*Process) error, report func(int, error)) error {
+	first := map[int]reading{}
+	err := collectProcesses(ctx, opts, func(p *Process) error {
+		if keep(p) {
+			first[p.PID] = reading{p, time.Now()}
+		}
+		return nil
+	}, func(int, error) {})
+	if err != nil {
+		return err
+	}
+
+	t := time.NewTimer(opts.Sample)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+
+	return collectProcesses(ctx, opts, func(p *Process) error {
+		if !keep(p) {
+			return nil
+		}
+		if r, ok := first[p.PID]; ok && sameProcess(r.p, p) {
+			setRates(p, r.p, time.Since(r.at).Seconds())
+		}
+		p.reportErrs(opts)
+		return fn(p)
+	}, report)
+}
+
+// sameProcess reports whether a and b, with the same PID, are one process
+// rather than a reused PID.
+func sameProcess(a, b *Process) bool {
+	d := time.Duration(a.StartTimeUnixNs - b.StartTimeUnixNs)
+	return d.Abs() <= startTimeSlack
+}
+
+// setRates sets the rates of p from the counters of its earlier reading
+// prev, sec seconds before.
+func setRates(p, prev *Process, sec float64) {
+	if sec <= 0 {
+		return
+	}
+	cpu := (p.CPUUserSeconds + p.CPUSystemSeconds) - (prev.CPUUserSeconds + prev.CPUSystemSeconds)
+	p.CPUPercent = round2(math.Max(cpu, 0) / sec * 100)
+	if p.MajorFaults != nil && prev.MajorFaults != nil {
+		p.MajorFaultsPerSec = rate(*p.MajorFaults, *prev.MajorFaults, sec)
+	}
+	if p.IO != nil && prev.IO != nil {
+		p.IO.ReadBytesPerSec = rate(p.IO.ReadBytes, prev.IO.ReadBytes, sec)
+		p.IO.WriteBytesPerSec = rate(p.IO.WriteBytes, prev.IO.WriteBytes, sec)
+	}
+}
+
+// rate is the per-second increase of a counter, or 0 if it went down.
+func rate(cur, prev uint64, sec float64) *float64 {
+	if cur < prev {
+		return round2(0)
+	}
+	return round2(float64(cur-prev) / sec)
+}
+
+func round2(v float64) *float64 {
+	v = math.Round(v*100) / 100
+	return &v
+}